@@ -0,0 +1,336 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"istio.io/libistio/galley/pkg/config/processing/transformer"
+	"istio.io/libistio/pkg/config/event"
+	"istio.io/libistio/pkg/config/schema/collection"
+)
+
+// Source is an event.Source, along with the collections that it produces.
+type Source struct {
+	// Name of the source, used when rendering the graph. If empty, a name is generated.
+	Name string
+
+	Source      event.Source
+	Collections collection.Schemas
+}
+
+// Spec is the specification of a pipeline.
+type Spec struct {
+	Sources   []Source
+	Providers transformer.Providers
+
+	// Outputs are the collections that are delivered to the pipeline's handler. Only the sources and transformers
+	// that are needed to produce the outputs become part of the pipeline.
+	Outputs collection.Names
+}
+
+// NodeKind is the kind of a node in the Graph.
+type NodeKind string
+
+const (
+	// SourceNode is a node for a Source.
+	SourceNode NodeKind = "source"
+
+	// TransformerNode is a node for a transformer.Provider.
+	TransformerNode NodeKind = "transformer"
+
+	// CollectionNode is a node for a collection.
+	CollectionNode NodeKind = "collection"
+
+	// OutputNode is the node for the handler that receives the outputs of the pipeline.
+	OutputNode NodeKind = "output"
+)
+
+// outputNodeID is the ID of the single OutputNode of a Graph.
+const outputNodeID = "output"
+
+// Node in the Graph.
+type Node struct {
+	ID   string   `json:"id"`
+	Kind NodeKind `json:"kind"`
+}
+
+// Edge in the Graph.
+type Edge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Graph is the validated DAG of sources, transformers and collections that is needed to produce the outputs of a
+// Spec. Sources and transformers are connected to the collections they produce, and collections are connected to
+// the transformers that consume them, as well as to the output node.
+type Graph struct {
+	spec Spec
+
+	// the producer of each collection
+	producers map[collection.Name]producer
+
+	// the schema of each collection
+	schemas map[collection.Name]collection.Schema
+
+	// indices of the needed sources, in spec order
+	sources []int
+
+	// indices of the needed providers, in topological order
+	providers []int
+
+	// needed collections, and the indices of the needed providers that consume them
+	consumers map[collection.Name][]int
+
+	outputs map[collection.Name]struct{}
+}
+
+type producer struct {
+	source bool
+	index  int
+}
+
+// New validates the given Spec and returns its Graph. An error is returned if a collection has more than one
+// producer, if the transformers form a cycle, or if an output or a needed transformer input has no producer.
+func New(spec Spec) (*Graph, error) {
+	g := &Graph{
+		spec:      spec,
+		producers: make(map[collection.Name]producer),
+		schemas:   make(map[collection.Name]collection.Schema),
+		consumers: make(map[collection.Name][]int),
+		outputs:   make(map[collection.Name]struct{}),
+	}
+
+	for i, src := range spec.Sources {
+		for _, s := range src.Collections.All() {
+			if err := g.addProducer(s, producer{source: true, index: i}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for i := range spec.Providers {
+		for _, s := range spec.Providers[i].Outputs().All() {
+			if err := g.addProducer(s, producer{index: i}); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	order, err := g.topologicalOrder()
+	if err != nil {
+		return nil, err
+	}
+
+	// Walk back from the outputs to find the needed collections, providers and sources.
+	neededProviders := make(map[int]struct{})
+	neededSources := make(map[int]struct{})
+	var visit func(c collection.Name) error
+	visit = func(c collection.Name) error {
+		if _, ok := g.consumers[c]; ok {
+			return nil
+		}
+		g.consumers[c] = nil
+
+		p, ok := g.producers[c]
+		if !ok {
+			return fmt.Errorf("no producer for collection: %v", c)
+		}
+		if p.source {
+			neededSources[p.index] = struct{}{}
+			return nil
+		}
+
+		neededProviders[p.index] = struct{}{}
+		for _, in := range spec.Providers[p.index].Inputs().All() {
+			if err := visit(in.Name()); err != nil {
+				return fmt.Errorf("%v (required by %v)", err, c)
+			}
+		}
+		return nil
+	}
+	for _, c := range spec.Outputs {
+		g.outputs[c] = struct{}{}
+		if err := visit(c); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := range spec.Sources {
+		if _, ok := neededSources[i]; ok {
+			g.sources = append(g.sources, i)
+		}
+	}
+	for _, i := range order {
+		if _, ok := neededProviders[i]; !ok {
+			continue
+		}
+		g.providers = append(g.providers, i)
+		for _, in := range spec.Providers[i].Inputs().All() {
+			g.consumers[in.Name()] = append(g.consumers[in.Name()], i)
+		}
+	}
+
+	return g, nil
+}
+
+func (g *Graph) addProducer(s collection.Schema, p producer) error {
+	if existing, ok := g.producers[s.Name()]; ok {
+		return fmt.Errorf("collection %v has multiple producers: %s and %s", s.Name(), g.nodeID(existing), g.nodeID(p))
+	}
+	g.producers[s.Name()] = p
+	g.schemas[s.Name()] = s
+	return nil
+}
+
+// topologicalOrder returns the indices of all providers, ordered so that each provider comes after the providers
+// that produce its inputs.
+func (g *Graph) topologicalOrder() ([]int, error) {
+	providers := g.spec.Providers
+
+	// dependents[i] are the providers that consume an output of provider i.
+	dependents := make([][]int, len(providers))
+	pending := make([]int, len(providers))
+	for i := range providers {
+		for _, in := range providers[i].Inputs().All() {
+			if p, ok := g.producers[in.Name()]; ok && !p.source {
+				dependents[p.index] = append(dependents[p.index], i)
+				pending[i]++
+			}
+		}
+	}
+
+	var ready, order []int
+	for i := range providers {
+		if pending[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+	for len(ready) > 0 {
+		i := ready[0]
+		ready = ready[1:]
+		order = append(order, i)
+		for _, d := range dependents[i] {
+			pending[d]--
+			if pending[d] == 0 {
+				ready = append(ready, d)
+			}
+		}
+	}
+
+	if len(order) != len(providers) {
+		var cycle []string
+		for i := range providers {
+			if pending[i] > 0 {
+				cycle = append(cycle, g.nodeID(producer{index: i}))
+			}
+		}
+		return nil, fmt.Errorf("transformers form a cycle: %s", strings.Join(cycle, ", "))
+	}
+	return order, nil
+}
+
+func (g *Graph) nodeID(p producer) string {
+	if p.source {
+		if name := g.spec.Sources[p.index].Name; name != "" {
+			return "source/" + name
+		}
+		return fmt.Sprintf("source/%d", p.index)
+	}
+	return fmt.Sprintf("transformer/%d", p.index)
+}
+
+func collectionNodeID(c collection.Name) string {
+	return "collection/" + c.String()
+}
+
+// Nodes returns the nodes of the graph, in a stable order.
+func (g *Graph) Nodes() []Node {
+	var nodes []Node
+	for _, i := range g.sources {
+		nodes = append(nodes, Node{ID: g.nodeID(producer{source: true, index: i}), Kind: SourceNode})
+	}
+	for _, i := range g.providers {
+		nodes = append(nodes, Node{ID: g.nodeID(producer{index: i}), Kind: TransformerNode})
+	}
+	for _, c := range g.collections() {
+		nodes = append(nodes, Node{ID: collectionNodeID(c), Kind: CollectionNode})
+	}
+	return append(nodes, Node{ID: outputNodeID, Kind: OutputNode})
+}
+
+// Edges returns the edges of the graph, in a stable order.
+func (g *Graph) Edges() []Edge {
+	var edges []Edge
+	for _, c := range g.collections() {
+		id := collectionNodeID(c)
+		edges = append(edges, Edge{From: g.nodeID(g.producers[c]), To: id})
+		for _, i := range g.consumers[c] {
+			edges = append(edges, Edge{From: id, To: g.nodeID(producer{index: i})})
+		}
+		if _, ok := g.outputs[c]; ok {
+			edges = append(edges, Edge{From: id, To: outputNodeID})
+		}
+	}
+	return edges
+}
+
+// collections returns the names of the needed collections, in sorted order.
+func (g *Graph) collections() collection.Names {
+	names := make(collection.Names, 0, len(g.consumers))
+	for c := range g.consumers {
+		names = append(names, c)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return names[i] < names[j]
+	})
+	return names
+}
+
+var shapes = map[NodeKind]string{
+	SourceNode:      "box",
+	TransformerNode: "component",
+	CollectionNode:  "ellipse",
+	OutputNode:      "doublecircle",
+}
+
+// DOT returns the graph in the Graphviz DOT format.
+func (g *Graph) DOT() string {
+	var b bytes.Buffer
+	b.WriteString("digraph pipeline {\n")
+	b.WriteString("  rankdir=LR;\n")
+	for _, n := range g.Nodes() {
+		fmt.Fprintf(&b, "  %q [shape=%s];\n", n.ID, shapes[n.Kind])
+	}
+	for _, e := range g.Edges() {
+		fmt.Fprintf(&b, "  %q -> %q;\n", e.From, e.To)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// MarshalJSON implements json.Marshaler
+func (g *Graph) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Nodes []Node `json:"nodes"`
+		Edges []Edge `json:"edges"`
+	}{
+		Nodes: g.Nodes(),
+		Edges: g.Edges(),
+	})
+}
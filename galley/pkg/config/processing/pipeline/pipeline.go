@@ -0,0 +1,181 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pipeline
+
+import (
+	"sync"
+
+	"istio.io/libistio/galley/pkg/config/processing"
+	"istio.io/libistio/galley/pkg/config/scope"
+	"istio.io/libistio/pkg/config/event"
+	"istio.io/libistio/pkg/config/schema/collection"
+)
+
+// Pipeline is a running instance of a Graph. It is an event.Processor that starts and stops the sources and
+// transformers of the graph in dependency order, and delivers the events for the output collections to a handler.
+// The Handle method of Pipeline is a no-op: all input events come from the sources of the graph.
+type Pipeline struct {
+	mu      sync.Mutex
+	started bool
+
+	sources []event.Source
+
+	// transformers, in topological order
+	transformers []event.Transformer
+}
+
+var _ event.Processor = &Pipeline{}
+
+// NewPipeline creates the transformers of the graph with the given options, and wires the sources, the transformers
+// and the handler together. The events of the output collections, as well as Reset events, are delivered to h.
+//
+// Sources are registered with event.Source.Dispatch, which can't be undone. A set of sources should therefore only
+// be wired into a single Pipeline.
+func (g *Graph) NewPipeline(o processing.ProcessorOptions, h event.Handler) *Pipeline {
+	p := &Pipeline{}
+
+	transformers := make(map[int]event.Transformer, len(g.providers))
+	for _, i := range g.providers {
+		xform := g.spec.Providers[i].Create(o)
+		transformers[i] = xform
+		p.transformers = append(p.transformers, xform)
+	}
+
+	handlerFor := func(c collection.Name) event.Handler {
+		var handler event.Handler
+		for _, i := range g.consumers[c] {
+			handler = event.CombineHandlers(handler, transformers[i])
+		}
+		if _, ok := g.outputs[c]; ok {
+			handler = event.CombineHandlers(handler, h)
+		}
+		return handler
+	}
+
+	for _, i := range g.providers {
+		for _, s := range g.spec.Providers[i].Outputs().All() {
+			if _, ok := g.consumers[s.Name()]; ok {
+				transformers[i].DispatchFor(s, handlerFor(s.Name()))
+			}
+		}
+	}
+
+	for _, i := range g.sources {
+		src := g.spec.Sources[i]
+		p.sources = append(p.sources, src.Source)
+
+		router := event.NewRouter()
+		var resetHandler event.Handler
+		seen := make(map[int]struct{})
+		resetOutput := false
+		for _, s := range src.Collections.All() {
+			if _, ok := g.consumers[s.Name()]; !ok {
+				continue
+			}
+			router = event.AddToRouter(router, s, handlerFor(s.Name()))
+
+			// Reset events have no collection, so they are delivered to each direct consumer of the source, once.
+			for _, j := range g.consumers[s.Name()] {
+				if _, ok := seen[j]; !ok {
+					seen[j] = struct{}{}
+					resetHandler = event.CombineHandlers(resetHandler, transformers[j])
+				}
+			}
+			if _, ok := g.outputs[s.Name()]; ok {
+				resetOutput = true
+			}
+		}
+		if resetOutput {
+			resetHandler = event.CombineHandlers(resetHandler, h)
+		}
+
+		src.Source.Dispatch(&sourceHandler{
+			name:   g.nodeID(producer{source: true, index: i}),
+			router: router,
+			reset:  resetHandler,
+			needed: g.consumers,
+		})
+	}
+
+	return p
+}
+
+// sourceHandler routes the events of a source to the consumers of its collections.
+type sourceHandler struct {
+	name   string
+	router event.Router
+	reset  event.Handler
+	needed map[collection.Name][]int
+}
+
+var _ event.Handler = &sourceHandler{}
+
+// Handle implements event.Handler
+func (s *sourceHandler) Handle(e event.Event) {
+	if e.Kind == event.Reset {
+		if s.reset != nil {
+			s.reset.Handle(e)
+		}
+		return
+	}
+
+	if _, ok := s.needed[e.SourceName()]; !ok {
+		scope.Processing.Debugf("Pipeline: %s: dropping event for unused collection: %v", s.name, e)
+		return
+	}
+	s.router.Handle(e)
+}
+
+// Start implements event.Processor. Transformers are started before the transformers and sources they depend on.
+func (p *Pipeline) Start() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.started {
+		return
+	}
+	p.started = true
+
+	scope.Processing.Debugf("Pipeline.Start: starting %d transformers and %d sources",
+		len(p.transformers), len(p.sources))
+	for i := len(p.transformers) - 1; i >= 0; i-- {
+		p.transformers[i].Start()
+	}
+	for _, src := range p.sources {
+		src.Start()
+	}
+}
+
+// Stop implements event.Processor. Sources are stopped first, followed by the transformers in dependency order.
+func (p *Pipeline) Stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.started {
+		return
+	}
+	p.started = false
+
+	scope.Processing.Debug("Pipeline.Stop: stopping")
+	for _, src := range p.sources {
+		src.Stop()
+	}
+	for _, xform := range p.transformers {
+		xform.Stop()
+	}
+}
+
+// Handle implements event.Processor
+func (p *Pipeline) Handle(_ event.Event) {}
@@ -0,0 +1,282 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package snapshotter builds MCP snapshots from the event pipeline and publishes them.
+package snapshotter
+
+import (
+	"fmt"
+	"sync"
+
+	mcp "istio.io/api/mcp/v1alpha1"
+
+	"istio.io/libistio/galley/pkg/config/scope"
+	"istio.io/libistio/pkg/config/event"
+	"istio.io/libistio/pkg/config/resource"
+	"istio.io/libistio/pkg/config/schema"
+	"istio.io/libistio/pkg/config/schema/collection"
+	"istio.io/libistio/pkg/mcp/snapshot"
+)
+
+// Distributor publishes snapshots. snapshot.Cache implements Distributor.
+type Distributor interface {
+	SetSnapshot(group string, s snapshot.Snapshot)
+}
+
+var _ Distributor = &snapshot.Cache{}
+
+// SnapshotOptions describes a snapshot produced by the Snapshotter.
+type SnapshotOptions struct {
+	// Group to publish the snapshot to.
+	Group string
+
+	// Distributor to publish the snapshot to.
+	Distributor Distributor
+
+	// Collections included in the snapshot.
+	Collections []collection.Name
+
+	// Strategy that decides when the snapshot is published.
+	Strategy Strategy
+}
+
+// OptionsFromMetadata returns the options for producing each of the named snapshots declared in the
+// metadata. Every snapshot is published to the group with the same name as the snapshot.
+func OptionsFromMetadata(m *schema.Metadata, names []string, d Distributor) ([]SnapshotOptions, error) {
+	declared := make(map[string]*schema.Snapshot)
+	for _, s := range m.AllSnapshots() {
+		declared[s.Name] = s
+	}
+
+	result := make([]SnapshotOptions, 0, len(names))
+	for _, name := range names {
+		s, ok := declared[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown snapshot: %q", name)
+		}
+
+		strategy, err := StrategyByName(s.Strategy)
+		if err != nil {
+			return nil, fmt.Errorf("snapshot %q: %v", name, err)
+		}
+
+		result = append(result, SnapshotOptions{
+			Group:       s.Name,
+			Distributor: d,
+			Collections: s.Collections,
+			Strategy:    strategy,
+		})
+	}
+	return result, nil
+}
+
+// Snapshotter is an event.Processor that keeps the latest state of a set of collections, and publishes
+// snapshots of them once every collection of a snapshot has received a FullSync event.
+type Snapshotter struct {
+	mu          sync.Mutex
+	started     bool
+	snapshots   []*snapshotState
+	collections map[collection.Name]*collectionState
+}
+
+var _ event.Processor = &Snapshotter{}
+
+// snapshotState is the publication state of a single snapshot.
+type snapshotState struct {
+	options     SnapshotOptions
+	collections []*collectionState
+}
+
+// collectionState is the latest state of a single collection.
+type collectionState struct {
+	name      collection.Name
+	synced    bool
+	resources map[resource.FullName]*mcp.Resource
+	snapshots []*snapshotState
+}
+
+// New returns a new Snapshotter for the given snapshots.
+func New(options []SnapshotOptions) (*Snapshotter, error) {
+	s := &Snapshotter{
+		collections: make(map[collection.Name]*collectionState),
+	}
+
+	groups := make(map[string]struct{})
+	for _, o := range options {
+		if o.Distributor == nil || o.Strategy == nil {
+			return nil, fmt.Errorf("snapshot %q: distributor and strategy must be specified", o.Group)
+		}
+		if _, ok := groups[o.Group]; ok {
+			return nil, fmt.Errorf("duplicate snapshot group: %q", o.Group)
+		}
+		groups[o.Group] = struct{}{}
+
+		st := &snapshotState{options: o}
+		for _, name := range o.Collections {
+			c, ok := s.collections[name]
+			if !ok {
+				c = &collectionState{
+					name:      name,
+					resources: make(map[resource.FullName]*mcp.Resource),
+				}
+				s.collections[name] = c
+			}
+			c.snapshots = append(c.snapshots, st)
+			st.collections = append(st.collections, c)
+		}
+		s.snapshots = append(s.snapshots, st)
+	}
+
+	return s, nil
+}
+
+// Inputs returns the names of the collections consumed by the Snapshotter.
+func (s *Snapshotter) Inputs() collection.Names {
+	result := make(collection.Names, 0, len(s.collections))
+	for name := range s.collections {
+		result = append(result, name)
+	}
+	result.Sort()
+	return result
+}
+
+// Start implements event.Processor
+func (s *Snapshotter) Start() {
+	s.mu.Lock()
+	if s.started {
+		s.mu.Unlock()
+		scope.Processing.Debug("Snapshotter.Start: already started")
+		return
+	}
+	s.started = true
+	s.mu.Unlock()
+
+	for _, st := range s.snapshots {
+		st := st
+		st.options.Strategy.Start(func() {
+			s.publish(st)
+		})
+	}
+}
+
+// Stop implements event.Processor
+func (s *Snapshotter) Stop() {
+	s.mu.Lock()
+	if !s.started {
+		s.mu.Unlock()
+		return
+	}
+	s.started = false
+	s.mu.Unlock()
+
+	for _, st := range s.snapshots {
+		st.options.Strategy.Stop()
+	}
+
+	// don't carry state over to the next start.
+	s.mu.Lock()
+	for _, c := range s.collections {
+		c.synced = false
+		c.resources = make(map[resource.FullName]*mcp.Resource)
+	}
+	s.mu.Unlock()
+}
+
+// Handle implements event.Handler
+func (s *Snapshotter) Handle(e event.Event) {
+	if e.Kind == event.Reset {
+		scope.Processing.Warnf("Snapshotter.Handle: ignoring reset event; the pipeline should be restarted")
+		return
+	}
+
+	s.mu.Lock()
+	if !s.started {
+		s.mu.Unlock()
+		return
+	}
+
+	c, ok := s.collections[e.SourceName()]
+	if !ok {
+		s.mu.Unlock()
+		scope.Processing.Warnf("Snapshotter.Handle: event for unexpected collection: %v", e)
+		return
+	}
+
+	switch e.Kind {
+	case event.Added, event.Updated:
+		r, err := resource.Serialize(e.Resource)
+		if err != nil {
+			s.mu.Unlock()
+			scope.Processing.Errorf("Snapshotter.Handle: unable to serialize resource, dropping event %v: %v", e, err)
+			return
+		}
+		c.resources[e.Resource.Metadata.FullName] = r
+
+	case event.Deleted:
+		delete(c.resources, e.Resource.Metadata.FullName)
+
+	case event.FullSync:
+		c.synced = true
+
+	default:
+		s.mu.Unlock()
+		scope.Processing.Errorf("Snapshotter.Handle: unexpected event: %v", e)
+		return
+	}
+
+	var changed []*snapshotState
+	for _, st := range c.snapshots {
+		if st.isSynced() {
+			changed = append(changed, st)
+		}
+	}
+	s.mu.Unlock()
+
+	// notify outside of the lock, as strategies may publish synchronously.
+	for _, st := range changed {
+		st.options.Strategy.OnChange()
+	}
+}
+
+// must be called with lock held
+func (st *snapshotState) isSynced() bool {
+	for _, c := range st.collections {
+		if !c.synced {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Snapshotter) publish(st *snapshotState) {
+	s.mu.Lock()
+	if !st.isSynced() {
+		s.mu.Unlock()
+		return
+	}
+
+	b := snapshot.NewInMemoryBuilder(snapshot.WithContentVersions())
+	for _, c := range st.collections {
+		resources := make([]*mcp.Resource, 0, len(c.resources))
+		for _, r := range c.resources {
+			resources = append(resources, r)
+		}
+		b.Set(c.name.String(), "", resources)
+	}
+	s.mu.Unlock()
+
+	sn := b.Build()
+	scope.Processing.Debugf("Snapshotter.publish: publishing snapshot for group %q:\n%v", st.options.Group, sn)
+	st.options.Distributor.SetSnapshot(st.options.Group, sn)
+}
@@ -0,0 +1,204 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snapshotter
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"istio.io/libistio/galley/pkg/config/scope"
+)
+
+const (
+	// ImmediateStrategy is the name of the strategy that publishes on every change.
+	ImmediateStrategy = "immediate"
+
+	// DebounceStrategy is the name of the strategy that waits for changes to settle before publishing.
+	DebounceStrategy = "debounce"
+
+	defaultMaxWaitDuration = time.Second
+	defaultQuiesceDuration = 100 * time.Millisecond
+)
+
+// Strategy decides when a snapshot should be published, based on change notifications.
+type Strategy interface {
+	// Start the strategy. publish is called whenever a snapshot should be published.
+	Start(publish func())
+
+	// Stop the strategy. publish is not called after Stop returns.
+	Stop()
+
+	// OnChange is called when the contents of a snapshot have changed.
+	OnChange()
+}
+
+// StrategyByName returns a new instance of the strategy with the given name, as used in schema.Snapshot.
+// An empty name selects the immediate strategy.
+func StrategyByName(name string) (Strategy, error) {
+	switch name {
+	case ImmediateStrategy, "":
+		return NewImmediate(), nil
+	case DebounceStrategy:
+		return NewDebounce(defaultMaxWaitDuration, defaultQuiesceDuration), nil
+	default:
+		return nil, fmt.Errorf("unknown snapshot strategy: %q", name)
+	}
+}
+
+// Immediate publishes synchronously on every change.
+type Immediate struct {
+	mu      sync.Mutex
+	publish func()
+}
+
+var _ Strategy = &Immediate{}
+
+// NewImmediate returns a new Immediate strategy.
+func NewImmediate() *Immediate {
+	return &Immediate{}
+}
+
+// Start implements Strategy
+func (s *Immediate) Start(publish func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.publish = publish
+}
+
+// Stop implements Strategy
+func (s *Immediate) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.publish = nil
+}
+
+// OnChange implements Strategy
+func (s *Immediate) OnChange() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.publish != nil {
+		s.publish()
+	}
+}
+
+// Debounce publishes once no change has been observed for the quiesce duration, or once the max wait
+// duration has elapsed since the first unpublished change, whichever comes first.
+type Debounce struct {
+	mu              sync.Mutex
+	maxWaitDuration time.Duration
+	quiesceDuration time.Duration
+
+	publish      func()
+	timer        *time.Timer
+	firstChange  time.Time
+	generation   int64
+	stopCh       chan struct{}
+	pendingTimer bool
+}
+
+var _ Strategy = &Debounce{}
+
+// NewDebounce returns a new Debounce strategy.
+func NewDebounce(maxWaitDuration, quiesceDuration time.Duration) *Debounce {
+	return &Debounce{
+		maxWaitDuration: maxWaitDuration,
+		quiesceDuration: quiesceDuration,
+	}
+}
+
+// Start implements Strategy
+func (s *Debounce) Start(publish func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopCh != nil {
+		scope.Processing.Debug("Debounce.Start: already started")
+		return
+	}
+	s.publish = publish
+	s.stopCh = make(chan struct{})
+}
+
+// Stop implements Strategy
+func (s *Debounce) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopCh == nil {
+		return
+	}
+	close(s.stopCh)
+	s.stopCh = nil
+	s.publish = nil
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	s.pendingTimer = false
+}
+
+// OnChange implements Strategy
+func (s *Debounce) OnChange() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopCh == nil {
+		return
+	}
+
+	now := time.Now()
+	if !s.pendingTimer {
+		s.firstChange = now
+		s.pendingTimer = true
+	}
+	s.generation++
+
+	wait := s.quiesceDuration
+	if remaining := s.maxWaitDuration - now.Sub(s.firstChange); remaining < wait {
+		wait = remaining
+	}
+
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	generation := s.generation
+	stopCh := s.stopCh
+	s.timer = time.AfterFunc(wait, func() {
+		s.fire(generation, stopCh)
+	})
+}
+
+func (s *Debounce) fire(generation int64, stopCh chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	select {
+	case <-stopCh:
+		return
+	default:
+	}
+
+	// a newer change re-armed the timer.
+	if generation != s.generation {
+		return
+	}
+
+	s.pendingTimer = false
+	s.timer = nil
+	if s.publish != nil {
+		s.publish()
+	}
+}
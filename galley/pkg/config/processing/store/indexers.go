@@ -0,0 +1,64 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"istio.io/api/networking/v1alpha3"
+
+	"istio.io/libistio/pkg/config/resource"
+	"istio.io/libistio/pkg/config/schema/collection"
+)
+
+// IndexFunc returns the values under which a resource is indexed. A resource may be indexed under any number of
+// values, including none.
+type IndexFunc func(c collection.Name, r *resource.Instance) []string
+
+// NamespaceIndex indexes resources by namespace. Cluster-scoped resources are indexed under the empty string.
+func NamespaceIndex(_ collection.Name, r *resource.Instance) []string {
+	return []string{r.Metadata.FullName.Namespace.String()}
+}
+
+// LabelIndex returns an IndexFunc that indexes resources by the value of the given label. Resources without the
+// label are not indexed.
+func LabelIndex(key string) IndexFunc {
+	return func(_ collection.Name, r *resource.Instance) []string {
+		if v, ok := r.Metadata.Labels[key]; ok {
+			return []string{v}
+		}
+		return nil
+	}
+}
+
+// HostIndex indexes networking resources by the hosts they refer to: the hosts of VirtualServices, ServiceEntries
+// and Gateway servers, and the host of DestinationRules. Other resources are not indexed.
+func HostIndex(_ collection.Name, r *resource.Instance) []string {
+	switch m := r.Message.(type) {
+	case *v1alpha3.VirtualService:
+		return m.Hosts
+	case *v1alpha3.ServiceEntry:
+		return m.Hosts
+	case *v1alpha3.DestinationRule:
+		if m.Host != "" {
+			return []string{m.Host}
+		}
+	case *v1alpha3.Gateway:
+		var hosts []string
+		for _, s := range m.Servers {
+			hosts = append(hosts, s.Hosts...)
+		}
+		return hosts
+	}
+	return nil
+}
@@ -0,0 +1,362 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"istio.io/libistio/galley/pkg/config/scope"
+	"istio.io/libistio/pkg/config/event"
+	"istio.io/libistio/pkg/config/resource"
+	"istio.io/libistio/pkg/config/schema/collection"
+)
+
+// View is a read-only view of the contents of a Store. Resources returned from a View are shared, and must not be
+// modified.
+type View interface {
+	// Get returns the resource with the given name in the given collection.
+	Get(c collection.Name, name resource.FullName) (*resource.Instance, bool)
+
+	// List returns the resources in the given collection, sorted by name.
+	List(c collection.Name) []*resource.Instance
+
+	// ByIndex returns the resources in the given collection that are indexed under the given value by the named
+	// indexer, sorted by name.
+	ByIndex(c collection.Name, index, value string) []*resource.Instance
+
+	// Synced returns true if a FullSync event has been received for the given collection.
+	Synced(c collection.Name) bool
+}
+
+// Change is a change to the contents of a Store, as seen by subscribers.
+type Change struct {
+	Kind       event.Kind
+	Collection collection.Name
+
+	// Old is the resource before the change. It is nil for Added events, and for FullSync and Reset events.
+	Old *resource.Instance
+
+	// New is the resource after the change. It is nil for Deleted events, and for FullSync and Reset events.
+	New *resource.Instance
+}
+
+// Store is an event.Handler that maintains the latest state of each resource it receives events for, keyed by
+// collection and name, along with a set of indices. It can be queried by transformers and analyzers that need
+// cross-collection state. A Reset event clears the store.
+type Store struct {
+	mu          sync.RWMutex
+	collections map[collection.Name]*collectionState
+	indexers    map[string]IndexFunc
+
+	subMu   sync.Mutex
+	subs    map[int]*subscription
+	nextSub int
+}
+
+var _ event.Handler = &Store{}
+var _ View = &Store{}
+
+type collectionState struct {
+	resources map[resource.FullName]*resource.Instance
+	synced    bool
+
+	// index name -> value -> resource names
+	indices map[string]map[string]map[resource.FullName]struct{}
+}
+
+type subscription struct {
+	collections map[collection.Name]struct{}
+	fn          func(Change)
+}
+
+// New returns a new Store with the given named indexers.
+func New(indexers map[string]IndexFunc) *Store {
+	s := &Store{
+		collections: make(map[collection.Name]*collectionState),
+		indexers:    make(map[string]IndexFunc, len(indexers)),
+		subs:        make(map[int]*subscription),
+	}
+	for name, fn := range indexers {
+		s.indexers[name] = fn
+	}
+	return s
+}
+
+// AddIndexer adds a new named indexer, and indexes the current contents of the store with it. An error is returned
+// if an indexer with the same name already exists.
+func (s *Store) AddIndexer(name string, fn IndexFunc) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.indexers[name]; ok {
+		return fmt.Errorf("indexer already exists: %q", name)
+	}
+	s.indexers[name] = fn
+
+	for c, st := range s.collections {
+		for _, r := range st.resources {
+			st.index(name, fn, c, r)
+		}
+	}
+	return nil
+}
+
+// Subscribe registers fn to be called with every change to the given collections, or to all collections if none
+// are specified. Reset changes are delivered to all subscribers. fn is called after the store has been updated, on
+// the goroutine that delivered the event, and must not block. The returned function cancels the subscription.
+func (s *Store) Subscribe(fn func(Change), collections ...collection.Name) (cancel func()) {
+	sub := &subscription{fn: fn}
+	if len(collections) > 0 {
+		sub.collections = make(map[collection.Name]struct{}, len(collections))
+		for _, c := range collections {
+			sub.collections[c] = struct{}{}
+		}
+	}
+
+	s.subMu.Lock()
+	id := s.nextSub
+	s.nextSub++
+	s.subs[id] = sub
+	s.subMu.Unlock()
+
+	return func() {
+		s.subMu.Lock()
+		delete(s.subs, id)
+		s.subMu.Unlock()
+	}
+}
+
+// Handle implements event.Handler
+func (s *Store) Handle(e event.Event) {
+	ch, ok := s.apply(e)
+	if !ok {
+		return
+	}
+
+	s.subMu.Lock()
+	var fns []func(Change)
+	for _, sub := range s.subs {
+		if sub.matches(ch) {
+			fns = append(fns, sub.fn)
+		}
+	}
+	s.subMu.Unlock()
+
+	for _, fn := range fns {
+		fn(ch)
+	}
+}
+
+func (s *Store) apply(e event.Event) (Change, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ch := Change{
+		Kind:       e.Kind,
+		Collection: e.SourceName(),
+	}
+
+	switch e.Kind {
+	case event.Reset:
+		s.collections = make(map[collection.Name]*collectionState)
+		return ch, true
+
+	case event.FullSync:
+		s.collection(ch.Collection).synced = true
+		return ch, true
+
+	case event.Added, event.Updated:
+		st := s.collection(ch.Collection)
+		name := e.Resource.Metadata.FullName
+		if old, ok := st.resources[name]; ok {
+			st.unindex(s.indexers, ch.Collection, old)
+			ch.Old = old
+		}
+		st.resources[name] = e.Resource
+		for index, fn := range s.indexers {
+			st.index(index, fn, ch.Collection, e.Resource)
+		}
+		ch.New = e.Resource
+		return ch, true
+
+	case event.Deleted:
+		st := s.collection(ch.Collection)
+		name := e.Resource.Metadata.FullName
+		old, ok := st.resources[name]
+		if !ok {
+			scope.Processing.Debugf("Store: delete of unknown resource: %v", e)
+			return ch, false
+		}
+		st.unindex(s.indexers, ch.Collection, old)
+		delete(st.resources, name)
+		ch.Old = old
+		return ch, true
+
+	default:
+		scope.Processing.Warnf("Store: unrecognized event: %v", e)
+		return ch, false
+	}
+}
+
+// must be called with lock held
+func (s *Store) collection(c collection.Name) *collectionState {
+	st, ok := s.collections[c]
+	if !ok {
+		st = &collectionState{
+			resources: make(map[resource.FullName]*resource.Instance),
+			indices:   make(map[string]map[string]map[resource.FullName]struct{}),
+		}
+		s.collections[c] = st
+	}
+	return st
+}
+
+func (st *collectionState) index(index string, fn IndexFunc, c collection.Name, r *resource.Instance) {
+	values := fn(c, r)
+	if len(values) == 0 {
+		return
+	}
+	byValue, ok := st.indices[index]
+	if !ok {
+		byValue = make(map[string]map[resource.FullName]struct{})
+		st.indices[index] = byValue
+	}
+	for _, v := range values {
+		names, ok := byValue[v]
+		if !ok {
+			names = make(map[resource.FullName]struct{})
+			byValue[v] = names
+		}
+		names[r.Metadata.FullName] = struct{}{}
+	}
+}
+
+func (st *collectionState) unindex(indexers map[string]IndexFunc, c collection.Name, r *resource.Instance) {
+	for index, fn := range indexers {
+		byValue := st.indices[index]
+		for _, v := range fn(c, r) {
+			names := byValue[v]
+			delete(names, r.Metadata.FullName)
+			if len(names) == 0 {
+				delete(byValue, v)
+			}
+		}
+	}
+}
+
+func (sub *subscription) matches(ch Change) bool {
+	if sub.collections == nil || ch.Kind == event.Reset {
+		return true
+	}
+	_, ok := sub.collections[ch.Collection]
+	return ok
+}
+
+// Read calls fn with a View of the store that doesn't change for the duration of the call. This allows consistent
+// queries across multiple collections. fn must not send events to the store.
+func (s *Store) Read(fn func(v View)) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	fn(unlockedView{s})
+}
+
+// Get implements View
+func (s *Store) Get(c collection.Name, name resource.FullName) (*resource.Instance, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return unlockedView{s}.Get(c, name)
+}
+
+// List implements View
+func (s *Store) List(c collection.Name) []*resource.Instance {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return unlockedView{s}.List(c)
+}
+
+// ByIndex implements View
+func (s *Store) ByIndex(c collection.Name, index, value string) []*resource.Instance {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return unlockedView{s}.ByIndex(c, index, value)
+}
+
+// Synced implements View
+func (s *Store) Synced(c collection.Name) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return unlockedView{s}.Synced(c)
+}
+
+// unlockedView implements View, for callers that already hold the read lock.
+type unlockedView struct {
+	s *Store
+}
+
+var _ View = unlockedView{}
+
+func (v unlockedView) Get(c collection.Name, name resource.FullName) (*resource.Instance, bool) {
+	st, ok := v.s.collections[c]
+	if !ok {
+		return nil, false
+	}
+	r, ok := st.resources[name]
+	return r, ok
+}
+
+func (v unlockedView) List(c collection.Name) []*resource.Instance {
+	st, ok := v.s.collections[c]
+	if !ok {
+		return nil
+	}
+	result := make([]*resource.Instance, 0, len(st.resources))
+	for _, r := range st.resources {
+		result = append(result, r)
+	}
+	sortByName(result)
+	return result
+}
+
+func (v unlockedView) ByIndex(c collection.Name, index, value string) []*resource.Instance {
+	st, ok := v.s.collections[c]
+	if !ok {
+		return nil
+	}
+	names := st.indices[index][value]
+	result := make([]*resource.Instance, 0, len(names))
+	for name := range names {
+		result = append(result, st.resources[name])
+	}
+	sortByName(result)
+	return result
+}
+
+func (v unlockedView) Synced(c collection.Name) bool {
+	st, ok := v.s.collections[c]
+	return ok && st.synced
+}
+
+func sortByName(resources []*resource.Instance) {
+	sort.Slice(resources, func(i, j int) bool {
+		return resources[i].Metadata.FullName.String() < resources[j].Metadata.FullName.String()
+	})
+}
@@ -0,0 +1,34 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package supervisor
+
+import (
+	"istio.io/pkg/monitoring"
+)
+
+var (
+	reasonTag = monitoring.MustCreateLabel("reason")
+
+	// restartsTotal is a measure of the number of times a supervisor restarted its pipeline.
+	restartsTotal = monitoring.NewSum(
+		"galley_supervisor_restarts_total",
+		"The number of times a supervised processing pipeline was restarted.",
+		monitoring.WithLabels(reasonTag),
+	)
+)
+
+func init() {
+	monitoring.MustRegister(restartsTotal)
+}
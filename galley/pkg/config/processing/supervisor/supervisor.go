@@ -0,0 +1,444 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package supervisor
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff"
+
+	"istio.io/api/mesh/v1alpha1"
+
+	"istio.io/libistio/galley/pkg/config/mesh"
+	"istio.io/libistio/galley/pkg/config/processing"
+	"istio.io/libistio/galley/pkg/config/processing/pipeline"
+	"istio.io/libistio/galley/pkg/config/scope"
+	"istio.io/libistio/pkg/config/event"
+	"istio.io/libistio/pkg/config/schema/collections"
+)
+
+const (
+	defaultInitialBackoff = 100 * time.Millisecond
+	defaultMaxBackoff     = 30 * time.Second
+
+	// maxHistory is the number of restarts that are retained in Status.
+	maxHistory = 16
+)
+
+// Options for a Supervisor.
+type Options struct {
+	// Spec of the supervised pipeline.
+	Spec pipeline.Spec
+
+	// DomainSuffix used in the ProcessorOptions of the transformers.
+	DomainSuffix string
+
+	// Handler receives the outputs of the pipeline. It also receives a Reset event every time the pipeline is
+	// stopped for a restart, so that it can discard its state. The handler may call Status, but it must not call
+	// Stop synchronously: Stop waits for the delivery of events to the handler to complete, so it would deadlock.
+	Handler event.Handler
+
+	// InitialBackoff is the delay before the first restart. Defaults to 100ms.
+	InitialBackoff time.Duration
+
+	// MaxBackoff is the maximum delay between restarts. Defaults to 30s. Once the pipeline has been running for
+	// longer than MaxBackoff, the delay is reset to InitialBackoff.
+	MaxBackoff time.Duration
+}
+
+// Restart records a single restart of the pipeline.
+type Restart struct {
+	Time   time.Time
+	Reason string
+}
+
+// Status of a Supervisor.
+type Status struct {
+	Running  bool
+	Restarts int
+
+	// History of the most recent restarts, oldest first.
+	History []Restart
+}
+
+type state int
+
+const (
+	stopped state = iota
+
+	// sources are started, and their events are buffered until the pipeline is wired.
+	buffering
+
+	running
+)
+
+// Supervisor owns a pipeline of sources and transformers, and restarts it whenever one of the sources sends a Reset
+// event. On every (re)start, the ProcessorOptions are rebuilt: if one of the sources produces the MeshConfig
+// collection, the transformers are only created once that collection has been fully synced, using the MeshConfig
+// it contains. Otherwise the default MeshConfig is used.
+type Supervisor struct {
+	options Options
+	graph   *pipeline.Graph
+	proxies []*proxy
+
+	// whether the supervisor needs to wait for the MeshConfig collection to be synced before starting the pipeline.
+	waitForMesh bool
+
+	// deliverMu serializes the delivery of events to the pipeline, which is done without holding mu. It must be
+	// acquired before mu.
+	deliverMu sync.Mutex
+
+	mu         sync.Mutex
+	state      state
+	pending    []pendingEvent
+	meshConfig *v1alpha1.MeshConfig
+	meshSynced chan struct{}
+	resetCh    chan string
+	stopCh     chan struct{}
+	doneCh     chan struct{}
+	restarts   int
+	history    []Restart
+}
+
+type pendingEvent struct {
+	proxy *proxy
+	e     event.Event
+}
+
+// New returns a new Supervisor. An error is returned if the pipeline spec is not valid.
+func New(o Options) (*Supervisor, error) {
+	if o.Handler == nil {
+		return nil, fmt.Errorf("supervisor: handler must be specified")
+	}
+	if o.InitialBackoff <= 0 {
+		o.InitialBackoff = defaultInitialBackoff
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = defaultMaxBackoff
+	}
+
+	s := &Supervisor{
+		resetCh: make(chan string, 1),
+	}
+
+	spec := o.Spec
+	spec.Sources = make([]pipeline.Source, len(o.Spec.Sources))
+	for i, src := range o.Spec.Sources {
+		name := src.Name
+		if name == "" {
+			name = fmt.Sprintf("%d", i)
+		}
+		p := &proxy{
+			name:       name,
+			source:     src.Source,
+			supervisor: s,
+		}
+		src.Source.Dispatch(p)
+		s.proxies = append(s.proxies, p)
+
+		if _, ok := src.Collections.Find(collections.IstioMeshV1Alpha1MeshConfig.Name().String()); ok {
+			s.waitForMesh = true
+		}
+
+		src.Source = p
+		spec.Sources[i] = src
+	}
+
+	g, err := pipeline.New(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	o.Spec = spec
+	s.options = o
+	s.graph = g
+	return s, nil
+}
+
+// Graph returns the graph of the supervised pipeline.
+func (s *Supervisor) Graph() *pipeline.Graph {
+	return s.graph
+}
+
+// Start the pipeline, and supervise it until Stop is called.
+func (s *Supervisor) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopCh != nil {
+		return
+	}
+	s.stopCh = make(chan struct{})
+	s.doneCh = make(chan struct{})
+	go s.run(s.stopCh, s.doneCh)
+}
+
+// Stop the pipeline. Stop does not return until the pipeline is stopped. It must not be called synchronously from
+// Options.Handler (see Options).
+func (s *Supervisor) Stop() {
+	s.mu.Lock()
+	if s.stopCh == nil {
+		s.mu.Unlock()
+		return
+	}
+	close(s.stopCh)
+	doneCh := s.doneCh
+	s.stopCh = nil
+	s.mu.Unlock()
+
+	<-doneCh
+}
+
+// Status returns the current status of the supervisor.
+func (s *Supervisor) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return Status{
+		Running:  s.state == running,
+		Restarts: s.restarts,
+		History:  append([]Restart(nil), s.history...),
+	}
+}
+
+func (s *Supervisor) run(stopCh, doneCh chan struct{}) {
+	defer close(doneCh)
+
+	b := backoff.NewExponentialBackOff()
+	b.InitialInterval = s.options.InitialBackoff
+	b.MaxInterval = s.options.MaxBackoff
+	b.MaxElapsedTime = 0
+	b.Reset()
+
+	for {
+		started := time.Now()
+		p, ok := s.start(stopCh)
+		if !ok {
+			s.stop(p)
+			return
+		}
+
+		var reason string
+		select {
+		case <-stopCh:
+			s.stop(p)
+			return
+		case reason = <-s.resetCh:
+		}
+
+		scope.Processing.Infof("Supervisor: restarting pipeline: %s", reason)
+		s.stop(p)
+		s.options.Handler.Handle(event.Event{Kind: event.Reset})
+		s.recordRestart(reason)
+
+		if time.Since(started) > s.options.MaxBackoff {
+			b.Reset()
+		}
+		t := time.NewTimer(b.NextBackOff())
+		select {
+		case <-stopCh:
+			t.Stop()
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// start the sources, wait for the MeshConfig if necessary, then create and start the pipeline. Returns false if
+// the supervisor was stopped while waiting.
+func (s *Supervisor) start(stopCh chan struct{}) (*pipeline.Pipeline, bool) {
+	s.mu.Lock()
+	s.state = buffering
+	s.pending = nil
+	s.meshConfig = nil
+	s.meshSynced = make(chan struct{})
+	meshSynced := s.meshSynced
+	// drain any stale reset request.
+	select {
+	case <-s.resetCh:
+	default:
+	}
+	s.mu.Unlock()
+
+	for _, p := range s.proxies {
+		p.source.Start()
+	}
+
+	if s.waitForMesh {
+		scope.Processing.Debug("Supervisor: waiting for mesh config")
+		select {
+		case <-stopCh:
+			return nil, false
+		case <-meshSynced:
+		}
+	}
+
+	s.mu.Lock()
+	o := processing.ProcessorOptions{
+		MeshConfig:   s.meshConfig,
+		DomainSuffix: s.options.DomainSuffix,
+	}
+	s.mu.Unlock()
+	if o.MeshConfig == nil {
+		o.MeshConfig = mesh.DefaultMeshConfig()
+	}
+
+	p := s.graph.NewPipeline(o, s.options.Handler)
+
+	// The proxies don't start the sources (which were started above), so this only starts the transformers.
+	// Events are still buffered.
+	p.Start()
+
+	// Hold deliverMu while flushing, so that new events are only delivered after the buffered ones.
+	s.deliverMu.Lock()
+	defer s.deliverMu.Unlock()
+
+	s.mu.Lock()
+	pending := s.pending
+	s.pending = nil
+	s.state = running
+	s.mu.Unlock()
+
+	for _, pe := range pending {
+		if h := pe.proxy.handler(); h != nil {
+			h.Handle(pe.e)
+		}
+	}
+
+	scope.Processing.Infof("Supervisor: pipeline started")
+	return p, true
+}
+
+func (s *Supervisor) stop(p *pipeline.Pipeline) {
+	// Acquiring deliverMu waits for any event that is being delivered to the pipeline.
+	s.deliverMu.Lock()
+	s.mu.Lock()
+	s.state = stopped
+	s.pending = nil
+	s.mu.Unlock()
+	s.deliverMu.Unlock()
+
+	for _, px := range s.proxies {
+		px.source.Stop()
+	}
+	if p != nil {
+		p.Stop()
+	}
+}
+
+func (s *Supervisor) recordRestart(reason string) {
+	restartsTotal.With(reasonTag.Value(reason)).Increment()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.restarts++
+	s.history = append(s.history, Restart{Time: time.Now(), Reason: reason})
+	if len(s.history) > maxHistory {
+		s.history = s.history[len(s.history)-maxHistory:]
+	}
+}
+
+func (s *Supervisor) handle(p *proxy, e event.Event) {
+	s.deliverMu.Lock()
+	defer s.deliverMu.Unlock()
+
+	if h := s.track(p, e); h != nil {
+		h.Handle(e)
+	}
+}
+
+// track records the effects of an event on the supervisor, and returns the handler the event should be delivered to,
+// if any. Events that arrive while the pipeline is being started are buffered.
+func (s *Supervisor) track(p *proxy, e event.Event) event.Handler {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.state == stopped {
+		return nil
+	}
+
+	if e.Kind == event.Reset {
+		select {
+		case s.resetCh <- "reset from source/" + p.name:
+		default:
+			// a restart is already pending.
+		}
+		return nil
+	}
+
+	if e.IsSource(collections.IstioMeshV1Alpha1MeshConfig.Name()) {
+		switch e.Kind {
+		case event.Added, event.Updated:
+			if cfg, ok := e.Resource.Message.(*v1alpha1.MeshConfig); ok {
+				s.meshConfig = cfg
+			}
+		case event.FullSync:
+			select {
+			case <-s.meshSynced:
+			default:
+				close(s.meshSynced)
+			}
+		}
+	}
+
+	if s.state == buffering {
+		s.pending = append(s.pending, pendingEvent{proxy: p, e: e})
+		return nil
+	}
+	return p.downstream
+}
+
+// proxy is an event.Source that stands in for a supervised source in the pipeline. The supervised source dispatches
+// to the proxy only once, and the proxy forwards events to the supervisor and to whichever pipeline is current. The
+// lifecycle of the supervised source is owned by the supervisor, so starting and stopping the proxy has no effect.
+type proxy struct {
+	name       string
+	source     event.Source
+	supervisor *Supervisor
+
+	// guarded by supervisor.mu
+	downstream event.Handler
+}
+
+var _ event.Source = &proxy{}
+
+// Dispatch implements event.Source. Unlike other sources, it replaces the current handler.
+func (p *proxy) Dispatch(h event.Handler) {
+	p.supervisor.mu.Lock()
+	defer p.supervisor.mu.Unlock()
+
+	p.downstream = h
+}
+
+// Start implements event.Source
+func (p *proxy) Start() {}
+
+// Stop implements event.Source
+func (p *proxy) Stop() {}
+
+// Handle implements event.Handler
+func (p *proxy) Handle(e event.Event) {
+	p.supervisor.handle(p, e)
+}
+
+func (p *proxy) handler() event.Handler {
+	p.supervisor.mu.Lock()
+	defer p.supervisor.mu.Unlock()
+
+	return p.downstream
+}
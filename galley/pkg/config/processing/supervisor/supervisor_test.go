@@ -0,0 +1,145 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package supervisor
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"istio.io/libistio/galley/pkg/config/processing/pipeline"
+	"istio.io/libistio/pkg/config/event"
+	"istio.io/libistio/pkg/config/schema/collection"
+	"istio.io/libistio/pkg/config/schema/collections"
+)
+
+type fakeSource struct {
+	mu      sync.Mutex
+	handler event.Handler
+	starts  int
+	stops   int
+}
+
+var _ event.Source = &fakeSource{}
+
+func (f *fakeSource) Dispatch(h event.Handler) {
+	f.handler = event.CombineHandlers(f.handler, h)
+}
+
+func (f *fakeSource) Start() {
+	f.mu.Lock()
+	f.starts++
+	f.mu.Unlock()
+	go f.handler.Handle(event.FullSyncFor(collections.K8SCoreV1Services))
+}
+
+func (f *fakeSource) Stop() {
+	f.mu.Lock()
+	f.stops++
+	f.mu.Unlock()
+}
+
+func newSupervisor(t *testing.T, src event.Source, h event.Handler) *Supervisor {
+	t.Helper()
+	s, err := New(Options{
+		Spec: pipeline.Spec{
+			Sources: []pipeline.Source{{
+				Name:        "src",
+				Source:      src,
+				Collections: collection.SchemasFor(collections.K8SCoreV1Services),
+			}},
+			Outputs: collection.Names{collections.K8SCoreV1Services.Name()},
+		},
+		Handler:        h,
+		InitialBackoff: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return s
+}
+
+func TestSupervisor_HandlerCallsStatusAndStopAsynchronously(t *testing.T) {
+	src := &fakeSource{}
+	stopped := make(chan struct{})
+
+	var s *Supervisor
+	var once sync.Once
+	s = newSupervisor(t, src, event.HandlerFromFn(func(e event.Event) {
+		if !s.Status().Running {
+			t.Errorf("expected the supervisor to be running while delivering %v", e)
+		}
+		once.Do(func() {
+			go func() {
+				s.Stop()
+				close(stopped)
+			}()
+		})
+	}))
+
+	s.Start()
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for Stop")
+	}
+
+	if s.Status().Running {
+		t.Fatal("expected the supervisor to be stopped")
+	}
+	src.mu.Lock()
+	defer src.mu.Unlock()
+	if src.starts != 1 || src.stops != 1 {
+		t.Fatalf("expected the source to be started and stopped once, got starts=%d stops=%d", src.starts, src.stops)
+	}
+}
+
+func TestSupervisor_RestartOnReset(t *testing.T) {
+	src := &fakeSource{}
+	events := make(chan event.Event, 10)
+
+	s := newSupervisor(t, src, event.HandlerFromFn(func(e event.Event) {
+		events <- e
+	}))
+	s.Start()
+	defer s.Stop()
+
+	next := func() event.Event {
+		select {
+		case e := <-events:
+			return e
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for event")
+			return event.Event{}
+		}
+	}
+
+	if e := next(); e.Kind != event.FullSync {
+		t.Fatalf("expected FullSync, got %v", e)
+	}
+	src.handler.Handle(event.Event{Kind: event.Reset})
+	if e := next(); e.Kind != event.Reset {
+		t.Fatalf("expected Reset, got %v", e)
+	}
+	if e := next(); e.Kind != event.FullSync {
+		t.Fatalf("expected FullSync after restart, got %v", e)
+	}
+
+	st := s.Status()
+	if st.Restarts != 1 || len(st.History) != 1 {
+		t.Fatalf("unexpected status: %+v", st)
+	}
+}
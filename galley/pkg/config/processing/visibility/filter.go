@@ -0,0 +1,217 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package visibility filters published snapshots down to the resources that are visible from a
+// namespace, based on the exportTo fields of the resources and the defaults in MeshConfig.
+package visibility
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/types"
+
+	mcp "istio.io/api/mcp/v1alpha1"
+	"istio.io/api/mesh/v1alpha1"
+	networking "istio.io/api/networking/v1alpha3"
+
+	"istio.io/libistio/galley/pkg/config/scope"
+	"istio.io/libistio/pkg/config/resource"
+	"istio.io/libistio/pkg/config/schema/collections"
+	"istio.io/libistio/pkg/mcp/snapshot"
+)
+
+const (
+	// Public makes a resource visible to all namespaces.
+	Public = "*"
+
+	// Private makes a resource visible only to its own namespace.
+	Private = "."
+
+	// None makes a resource visible to no namespace.
+	None = "~"
+
+	// NamespaceAnnotation is the SinkNode annotation through which a sink declares its namespace.
+	NamespaceAnnotation = "istio.io/namespace"
+)
+
+// exportTo is implemented by the resource types that support exportTo.
+type exportTo interface {
+	proto.Message
+	GetExportTo() []string
+}
+
+// exportable describes a collection whose resources support exportTo.
+type exportable struct {
+	defaults []string
+	newProto func() exportTo
+}
+
+// Filter drops the ServiceEntries, VirtualServices and DestinationRules that are not exported to a
+// namespace from snapshots. Resources without exportTo use the corresponding MeshConfig default.
+type Filter struct {
+	collections map[string]exportable
+
+	// fingerprint of the defaults, used to version filtered collections.
+	fingerprint string
+}
+
+// NewFilter returns a new Filter using the exportTo defaults of the given MeshConfig.
+func NewFilter(m *v1alpha1.MeshConfig) *Filter {
+	cols := map[string]exportable{
+		collections.IstioNetworkingV1Alpha3Serviceentries.Name().String(): {
+			defaults: m.GetDefaultServiceExportTo(),
+			newProto: func() exportTo { return &networking.ServiceEntry{} },
+		},
+		collections.IstioNetworkingV1Alpha3Virtualservices.Name().String(): {
+			defaults: m.GetDefaultVirtualServiceExportTo(),
+			newProto: func() exportTo { return &networking.VirtualService{} },
+		},
+		collections.IstioNetworkingV1Alpha3Destinationrules.Name().String(): {
+			defaults: m.GetDefaultDestinationRuleExportTo(),
+			newProto: func() exportTo { return &networking.DestinationRule{} },
+		},
+	}
+
+	h := sha256.New()
+	for _, c := range []string{
+		collections.IstioNetworkingV1Alpha3Serviceentries.Name().String(),
+		collections.IstioNetworkingV1Alpha3Virtualservices.Name().String(),
+		collections.IstioNetworkingV1Alpha3Destinationrules.Name().String(),
+	} {
+		_, _ = h.Write([]byte(strings.Join(cols[c].defaults, ",")))
+		_, _ = h.Write([]byte{0})
+	}
+
+	return &Filter{
+		collections: cols,
+		fingerprint: base64.RawStdEncoding.EncodeToString(h.Sum(nil)[:8]),
+	}
+}
+
+// Filters returns true if the filter applies to the given collection.
+func (f *Filter) Filters(collection string) bool {
+	_, ok := f.collections[collection]
+	return ok
+}
+
+// IsVisible returns true if the resource in the given collection is exported to the namespace.
+// Resources in collections that the filter does not apply to are always visible.
+func (f *Filter) IsVisible(collection string, r *mcp.Resource, namespace string) (bool, error) {
+	c, ok := f.collections[collection]
+	if !ok {
+		return true, nil
+	}
+
+	e := c.newProto()
+	if err := types.UnmarshalAny(r.Body, e); err != nil {
+		return false, fmt.Errorf("error unmarshaling %s in collection %s: %v", r.Metadata.Name, collection, err)
+	}
+
+	values := e.GetExportTo()
+	if len(values) == 0 {
+		values = c.defaults
+	}
+
+	name, err := resource.ParseFullName(r.Metadata.Name)
+	if err != nil {
+		return false, err
+	}
+
+	return isExportedTo(values, string(name.Namespace), namespace), nil
+}
+
+// isExportedTo returns true if the exportTo values of a resource in the given namespace make it
+// visible to the target namespace. An empty set of values makes the resource public.
+func isExportedTo(values []string, resourceNamespace, namespace string) bool {
+	if len(values) == 0 {
+		return true
+	}
+
+	for _, v := range values {
+		switch v {
+		case Public:
+			return true
+		case Private:
+			if resourceNamespace == namespace {
+				return true
+			}
+		case None:
+		default:
+			if v == namespace {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Apply returns a snapshot that only contains the resources of s that are visible to the namespace.
+// The versions of filtered collections are derived from the original versions, the namespace and
+// the MeshConfig defaults, so that sinks are pushed new contents if any of these change. Resources
+// that cannot be decoded are dropped.
+func (f *Filter) Apply(s snapshot.Snapshot, namespace string) snapshot.Snapshot {
+	b := snapshot.NewInMemoryBuilder()
+
+	for _, collection := range s.Collections() {
+		version := s.Version(collection)
+		if !f.Filters(collection) {
+			b.Set(collection, version, s.Resources(collection))
+			continue
+		}
+
+		var visible []*mcp.Resource
+		for _, r := range s.Resources(collection) {
+			ok, err := f.IsVisible(collection, r, namespace)
+			if err != nil {
+				scope.Processing.Errorf("visibility.Filter: dropping resource: %v", err)
+				continue
+			}
+			if ok {
+				visible = append(visible, r)
+			}
+		}
+		b.Set(collection, fmt.Sprintf("%s@%s/%s", version, namespace, f.fingerprint), visible)
+	}
+
+	return b.Build()
+}
+
+// GroupIndex returns a snapshot.GroupIndexFn that assigns sinks declaring their namespace through
+// NamespaceAnnotation to the group returned by NamespaceGroup. Sinks that do not declare a namespace
+// use the fallback.
+func GroupIndex(prefix string, fallback snapshot.GroupIndexFn) snapshot.GroupIndexFn {
+	return snapshot.FirstOf(fallback, func(_ string, node *mcp.SinkNode) (string, bool) {
+		ns := node.GetAnnotations()[NamespaceAnnotation]
+		if ns == "" {
+			return "", false
+		}
+		return NamespaceGroup(prefix, ns), true
+	})
+}
+
+// NamespaceGroup returns the group of the given namespace, as assigned by GroupIndex.
+func NamespaceGroup(prefix, namespace string) string {
+	return prefix + namespace
+}
+
+// Publish sets the snapshot for the group of each namespace, filtered for that namespace.
+func (f *Filter) Publish(c *snapshot.Cache, s snapshot.Snapshot, prefix string, namespaces []string) {
+	for _, ns := range namespaces {
+		c.SetSnapshot(NamespaceGroup(prefix, ns), f.Apply(s, ns))
+	}
+}
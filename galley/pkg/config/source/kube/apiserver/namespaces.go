@@ -0,0 +1,308 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apiserver
+
+import (
+	"sort"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/labels"
+
+	"istio.io/libistio/galley/pkg/config/scope"
+	"istio.io/libistio/galley/pkg/config/source/kube/rt"
+	"istio.io/libistio/pkg/config/event"
+	"istio.io/libistio/pkg/config/resource"
+	"istio.io/libistio/pkg/config/schema/collection"
+	"istio.io/libistio/pkg/config/schema/collections"
+)
+
+// namespaceController watches the namespaced collections in the set of namespaces that match a label selector
+// (see Options.NamespaceSelector). The set of namespaces is discovered by watching Namespaces, and is kept up to
+// date at runtime: when a namespace starts matching the selector, watchers are started for it, and when it stops
+// matching (or is deleted), its watchers are stopped and Deleted events are published for its resources.
+type namespaceController struct {
+	mu sync.Mutex
+
+	selector    labels.Selector
+	schemas     []collection.Schema
+	revision    RevisionOptions
+	handler     event.Handler
+	newProvider func(namespace string) *rt.Provider
+
+	nsWatcher *watcher
+	started   bool
+
+	// indicates that the initial set of namespaces is known.
+	initialized bool
+
+	// selected namespaces
+	namespaces map[string]*namespaceWatch
+
+	// the number of initially selected namespaces that haven't synced yet, for each collection that hasn't had its
+	// FullSync published yet.
+	pendingSync map[collection.Name]int
+}
+
+// namespaceWatch is the set of watchers for a single selected namespace.
+type namespaceWatch struct {
+	name string
+
+	// guarded by namespaceController.mu
+	active    bool
+	synced    map[collection.Name]bool
+	resources map[collection.Name]map[resource.FullName]*resource.Instance
+	// initial indicates that the namespace was selected when namespace discovery completed. Only the initial
+	// namespaces hold up the FullSync of the collections.
+	initial bool
+
+	// serializes the starting and stopping of the watchers.
+	watchMu  sync.Mutex
+	stopped  bool
+	watchers []*watcher
+}
+
+func newNamespaceController(selector labels.Selector, schemas []collection.Schema, revision RevisionOptions,
+	handler event.Handler, newProvider func(namespace string) *rt.Provider) *namespaceController {
+	return &namespaceController{
+		selector:    selector,
+		schemas:     schemas,
+		revision:    revision,
+		handler:     handler,
+		newProvider: newProvider,
+	}
+}
+
+func (c *namespaceController) start(nsAdapter *rt.Adapter) {
+	c.mu.Lock()
+	c.started = true
+	c.initialized = false
+	c.namespaces = make(map[string]*namespaceWatch)
+	c.pendingSync = make(map[collection.Name]int)
+	for _, s := range c.schemas {
+		c.pendingSync[s.Name()] = 0
+	}
+	c.nsWatcher = newWatcher(collections.K8SCoreV1Namespaces, nsAdapter, nil)
+	c.nsWatcher.dispatch(event.HandlerFromFn(c.onNamespaceEvent))
+	w := c.nsWatcher
+	c.mu.Unlock()
+
+	scope.Source.Infof("Discovering namespaces matching selector %q", c.selector)
+	w.start()
+}
+
+func (c *namespaceController) stop() {
+	c.mu.Lock()
+	c.started = false
+	w := c.nsWatcher
+	c.nsWatcher = nil
+	var toStop []*namespaceWatch
+	for _, nw := range c.namespaces {
+		nw.active = false
+		toStop = append(toStop, nw)
+	}
+	c.namespaces = nil
+	c.mu.Unlock()
+
+	if w != nil {
+		w.stop()
+	}
+	for _, nw := range toStop {
+		nw.stop()
+	}
+}
+
+func (c *namespaceController) onNamespaceEvent(e event.Event) {
+	var toStart, toStop []*namespaceWatch
+
+	c.mu.Lock()
+	if !c.started {
+		c.mu.Unlock()
+		return
+	}
+
+	switch e.Kind {
+	case event.Added, event.Updated, event.Deleted:
+		name := string(e.Resource.Metadata.FullName.Name)
+		selected := e.Kind != event.Deleted && c.selector.Matches(labels.Set(e.Resource.Metadata.Labels))
+		nw, watched := c.namespaces[name]
+
+		switch {
+		case selected && !watched:
+			scope.Source.Infof("Namespace %q is selected, watching its resources", name)
+			nw = c.newNamespaceWatch(name)
+			c.namespaces[name] = nw
+			if c.initialized {
+				toStart = append(toStart, nw)
+			}
+
+		case !selected && watched:
+			scope.Source.Infof("Namespace %q is no longer selected, removing its resources", name)
+			c.remove(nw)
+			toStop = append(toStop, nw)
+		}
+
+	case event.FullSync:
+		if c.initialized {
+			break
+		}
+		c.initialized = true
+		scope.Source.Infof("Namespace discovery complete, %d namespaces selected", len(c.namespaces))
+		for _, nw := range c.namespaces {
+			nw.initial = true
+			toStart = append(toStart, nw)
+		}
+		for _, s := range c.schemas {
+			c.pendingSync[s.Name()] = len(c.namespaces)
+			if len(c.namespaces) == 0 {
+				delete(c.pendingSync, s.Name())
+				c.handler.Handle(event.FullSyncFor(s))
+			}
+		}
+	}
+	c.mu.Unlock()
+
+	// Watchers block until their caches are synced, so they are started and stopped without holding the lock.
+	for _, nw := range toStop {
+		nw.stop()
+	}
+	for _, nw := range toStart {
+		nw.start()
+	}
+}
+
+// must be called with lock held
+func (c *namespaceController) newNamespaceWatch(name string) *namespaceWatch {
+	nw := &namespaceWatch{
+		name:      name,
+		active:    true,
+		synced:    make(map[collection.Name]bool),
+		resources: make(map[collection.Name]map[resource.FullName]*resource.Instance),
+	}
+
+	p := c.newProvider(name)
+	for _, s := range c.schemas {
+		s := s
+		w := newWatcher(s, p.GetAdapter(s.Resource()), c.revision.predicateFor(s.Name()))
+		w.dispatch(event.HandlerFromFn(func(e event.Event) {
+			c.onResourceEvent(nw, s, e)
+		}))
+		nw.watchers = append(nw.watchers, w)
+	}
+	return nw
+}
+
+// remove the namespace from the selected set, and publish Deleted events for its resources. Must be called with
+// lock held.
+func (c *namespaceController) remove(nw *namespaceWatch) {
+	nw.active = false
+	delete(c.namespaces, nw.name)
+
+	for _, s := range c.schemas {
+		byName := nw.resources[s.Name()]
+		names := make([]resource.FullName, 0, len(byName))
+		for name := range byName {
+			names = append(names, name)
+		}
+		sort.Slice(names, func(i, j int) bool {
+			return names[i].String() < names[j].String()
+		})
+		for _, name := range names {
+			c.handler.Handle(event.Event{
+				Kind:     event.Deleted,
+				Source:   s,
+				Resource: byName[name],
+			})
+		}
+
+		// Don't let a namespace that is removed during the initial sync hold up the FullSync.
+		if nw.initial && !nw.synced[s.Name()] {
+			c.collectionSynced(s)
+		}
+	}
+	nw.resources = nil
+}
+
+func (c *namespaceController) onResourceEvent(nw *namespaceWatch, s collection.Schema, e event.Event) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !nw.active {
+		// The namespace was removed while the event was in flight.
+		return
+	}
+
+	switch e.Kind {
+	case event.FullSync:
+		if !nw.synced[s.Name()] {
+			nw.synced[s.Name()] = true
+			if nw.initial {
+				c.collectionSynced(s)
+			}
+		}
+		return
+
+	case event.Added, event.Updated:
+		byName, ok := nw.resources[s.Name()]
+		if !ok {
+			byName = make(map[resource.FullName]*resource.Instance)
+			nw.resources[s.Name()] = byName
+		}
+		byName[e.Resource.Metadata.FullName] = e.Resource
+
+	case event.Deleted:
+		delete(nw.resources[s.Name()], e.Resource.Metadata.FullName)
+	}
+
+	c.handler.Handle(e)
+}
+
+// collectionSynced records that one of the initially selected namespaces has synced for the given collection, and
+// publishes the FullSync for the collection once all of them have. Must be called with lock held.
+func (c *namespaceController) collectionSynced(s collection.Schema) {
+	n, ok := c.pendingSync[s.Name()]
+	if !ok {
+		// FullSync was already published. Namespaces that are selected later are synced incrementally.
+		return
+	}
+	n--
+	if n > 0 {
+		c.pendingSync[s.Name()] = n
+		return
+	}
+	delete(c.pendingSync, s.Name())
+	c.handler.Handle(event.FullSyncFor(s))
+}
+
+func (nw *namespaceWatch) start() {
+	nw.watchMu.Lock()
+	defer nw.watchMu.Unlock()
+
+	if nw.stopped {
+		return
+	}
+	for _, w := range nw.watchers {
+		w.start()
+	}
+}
+
+func (nw *namespaceWatch) stop() {
+	nw.watchMu.Lock()
+	defer nw.watchMu.Unlock()
+
+	nw.stopped = true
+	for _, w := range nw.watchers {
+		w.stop()
+	}
+}
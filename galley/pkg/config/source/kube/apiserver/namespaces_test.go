@@ -0,0 +1,140 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apiserver
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	extfake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+
+	"istio.io/libistio/galley/pkg/config/source/kube"
+	"istio.io/libistio/pkg/config/event"
+	"istio.io/libistio/pkg/config/schema/collection"
+	"istio.io/libistio/pkg/config/schema/collections"
+)
+
+type fakeInterfaces struct {
+	kube kubernetes.Interface
+	ext  clientset.Interface
+}
+
+var _ kube.Interfaces = &fakeInterfaces{}
+
+func (f *fakeInterfaces) DynamicInterface() (dynamic.Interface, error) {
+	return nil, nil
+}
+
+func (f *fakeInterfaces) APIExtensionsClientset() (clientset.Interface, error) {
+	return f.ext, nil
+}
+
+func (f *fakeInterfaces) KubeClient() (kubernetes.Interface, error) {
+	return f.kube, nil
+}
+
+type accumulator struct {
+	mu     sync.Mutex
+	events []event.Event
+}
+
+func (a *accumulator) Handle(e event.Event) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.events = append(a.events, e)
+}
+
+// waitForFullSync waits until a FullSync was received for each of the given collections, and returns the resources
+// that were added, by collection.
+func (a *accumulator) waitForFullSync(t *testing.T, names ...collection.Name) map[collection.Name][]string {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		a.mu.Lock()
+		synced := make(map[collection.Name]bool)
+		added := make(map[collection.Name][]string)
+		for _, e := range a.events {
+			switch e.Kind {
+			case event.FullSync:
+				synced[e.SourceName()] = true
+			case event.Added:
+				added[e.SourceName()] = append(added[e.SourceName()], e.Resource.Metadata.FullName.String())
+			}
+		}
+		a.mu.Unlock()
+
+		done := true
+		for _, n := range names {
+			done = done && synced[n]
+		}
+		if done {
+			for _, v := range added {
+				sort.Strings(v)
+			}
+			return added
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for FullSync of %v", names)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func namespace(name string, labels map[string]string) *v1.Namespace {
+	return &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+}
+
+func service(namespace, name string) *v1.Service {
+	return &v1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}
+}
+
+func TestSource_NamespaceSelectorWithNamespacesCollection(t *testing.T) {
+	client := kubefake.NewSimpleClientset(
+		namespace("selected", map[string]string{"istio-injection": "enabled"}),
+		namespace("other", nil),
+		service("selected", "s1"),
+		service("other", "s2"))
+
+	s := New(Options{
+		Client:            &fakeInterfaces{kube: client, ext: extfake.NewSimpleClientset()},
+		Schemas:           collection.SchemasFor(collections.K8SCoreV1Namespaces, collections.K8SCoreV1Services),
+		NamespaceSelector: "istio-injection=enabled",
+	})
+	acc := &accumulator{}
+	s.Dispatch(acc)
+
+	s.Start()
+	added := acc.waitForFullSync(t, collections.K8SCoreV1Namespaces.Name(), collections.K8SCoreV1Services.Name())
+
+	// The Namespaces collection isn't scoped by the selector, and each namespace is published once.
+	if got := added[collections.K8SCoreV1Namespaces.Name()]; len(got) != 2 || got[0] != "other" || got[1] != "selected" {
+		t.Fatalf("unexpected namespaces: %v", got)
+	}
+	if got := added[collections.K8SCoreV1Services.Name()]; len(got) != 1 || got[0] != "selected/s1" {
+		t.Fatalf("unexpected services: %v", got)
+	}
+
+	// Stopping must not stop an informer that is shared between the namespace controller and the Namespaces watcher.
+	s.Stop()
+}
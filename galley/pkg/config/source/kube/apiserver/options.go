@@ -15,9 +15,15 @@
 package apiserver
 
 import (
+	"fmt"
 	"time"
 
+	"github.com/hashicorp/go-multierror"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"istio.io/libistio/galley/pkg/config/scope"
 	"istio.io/libistio/galley/pkg/config/source/kube"
+	"istio.io/libistio/galley/pkg/config/source/kube/rt"
 	"istio.io/libistio/pkg/config/schema/collection"
 )
 
@@ -31,4 +37,76 @@ type Options struct {
 	Schemas collection.Schemas
 
 	WatchedNamespaces string
+
+	// NamespaceSelector, if set, is a label selector for the namespaces whose resources are watched. Namespaced
+	// collections are then watched in each of the selected namespaces, and WatchedNamespaces only applies to
+	// cluster-scoped collections. As namespaces start or stop matching the selector, Added and Deleted events are
+	// published for their resources, without restarting the source.
+	NamespaceSelector string
+
+	// Selectors optionally scope the objects that are listed and watched for each collection. Collections without a
+	// selector are listed and watched in their entirety. A selector for the CustomResourceDefinition collection also
+	// scopes the discovery of custom resource types: custom resources whose definition isn't selected aren't watched.
+	Selectors map[collection.Name]Selector
+
+	// Revision filters resources by the control plane revision they belong to (see RevisionOptions).
+	Revision RevisionOptions
+}
+
+// Selector scopes the objects of a collection that are listed and watched from the API server.
+type Selector struct {
+	// LabelSelector in the format accepted by labels.Parse, e.g. "istio.io/rev=canary".
+	LabelSelector string
+
+	// FieldSelector in the format accepted by fields.ParseSelector, e.g. "metadata.name!=default".
+	FieldSelector string
+}
+
+// Validate the options. Selectors that can't be parsed are rejected.
+func (o *Options) Validate() (err error) {
+	if _, e := labels.Parse(o.NamespaceSelector); e != nil {
+		err = multierror.Append(err, fmt.Errorf("invalid namespace selector: %v", e))
+	}
+	for name, sel := range o.Selectors {
+		s, found := o.Schemas.Find(name.String())
+		if !found {
+			// selectors for unknown collections are ignored (see providerSelectors).
+			continue
+		}
+		r := rt.Selector{
+			Resource: s.Resource(),
+			Label:    sel.LabelSelector,
+			Field:    sel.FieldSelector,
+		}
+		err = multierror.Append(err, r.Validate()).ErrorOrNil()
+	}
+	return
+}
+
+// providerSelectors converts the selectors to their rt equivalents. Selectors for collections that are not in
+// Schemas are logged and skipped. The options are expected to be valid (see Validate).
+func (o *Options) providerSelectors() []rt.Selector {
+	var result []rt.Selector
+	for name, sel := range o.Selectors {
+		s, found := o.Schemas.Find(name.String())
+		if !found {
+			scope.Source.Warnf("Ignoring selector for unknown collection: %v", name)
+			continue
+		}
+
+		r := rt.Selector{
+			Resource: s.Resource(),
+			Label:    sel.LabelSelector,
+			Field:    sel.FieldSelector,
+		}
+		if err := r.Validate(); err != nil {
+			scope.Source.Errorf("Ignoring selector for collection %v: %v", name, err)
+			continue
+		}
+
+		scope.Source.Infof("Scoping watch of %v to label selector %q, field selector %q",
+			name, sel.LabelSelector, sel.FieldSelector)
+		result = append(result, r)
+	}
+	return result
 }
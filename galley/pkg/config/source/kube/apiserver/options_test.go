@@ -0,0 +1,93 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apiserver
+
+import (
+	"testing"
+
+	"istio.io/libistio/pkg/config/event"
+	"istio.io/libistio/pkg/config/schema/collection"
+	"istio.io/libistio/pkg/config/schema/collections"
+)
+
+func TestOptions_Validate(t *testing.T) {
+	cases := []struct {
+		name     string
+		selector Selector
+		valid    bool
+	}{
+		{name: "empty", selector: Selector{}, valid: true},
+		{name: "valid", selector: Selector{LabelSelector: "app=foo", FieldSelector: "metadata.name!=bar"}, valid: true},
+		{name: "invalid label", selector: Selector{LabelSelector: "app in (foo"}, valid: false},
+		{name: "invalid field", selector: Selector{FieldSelector: "metadata.name~bar"}, valid: false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			o := Options{
+				Schemas: collection.SchemasFor(collections.K8SCoreV1Services),
+				Selectors: map[collection.Name]Selector{
+					collections.K8SCoreV1Services.Name(): c.selector,
+				},
+			}
+			err := o.Validate()
+			if c.valid && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !c.valid && err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestOptions_ValidateNamespaceSelector(t *testing.T) {
+	o := Options{NamespaceSelector: "istio-injection=enabled"}
+	if err := o.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	o.NamespaceSelector = "istio-injection in (enabled"
+	if err := o.Validate(); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestSource_InvalidSelectorFailsClosed(t *testing.T) {
+	schemas := collection.SchemasFor(collections.K8SCoreV1Services, collections.K8SCoreV1Pods)
+	s := New(Options{
+		Schemas: schemas,
+		Selectors: map[collection.Name]Selector{
+			collections.K8SCoreV1Services.Name(): {LabelSelector: "app in (foo"},
+		},
+	})
+
+	var got []event.Event
+	s.Dispatch(event.HandlerFromFn(func(e event.Event) {
+		got = append(got, e)
+	}))
+	s.Start()
+	defer s.Stop()
+
+	// No client is set, so reaching the API server would fail: only FullSync events are expected.
+	if len(got) != len(schemas.All()) {
+		t.Fatalf("expected %d events, got %v", len(schemas.All()), got)
+	}
+	for i, r := range schemas.All() {
+		if got[i].Kind != event.FullSync || got[i].Source.Name() != r.Name() {
+			t.Fatalf("expected FullSync for %v, got %v", r.Name(), got[i])
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apiserver
+
+import (
+	"strings"
+
+	"istio.io/libistio/galley/pkg/config/scope"
+	"istio.io/libistio/galley/pkg/config/source/kube/apiserver/stats"
+	"istio.io/libistio/pkg/config/event"
+	"istio.io/libistio/pkg/config/resource"
+	"istio.io/libistio/pkg/config/schema/collection"
+)
+
+// RevisionOptions configure the filtering of resources by control plane revision. Resources are associated with a
+// revision by the istio.io/rev label.
+type RevisionOptions struct {
+	// Revision of the control plane. If empty, resources are not filtered by revision.
+	Revision string
+
+	// Aliases (e.g. revision tags) that are accepted in addition to Revision.
+	Aliases []string
+
+	// Collections that are filtered by revision. If empty, all the Istio configuration collections (i.e. the ones
+	// whose names start with "istio/") are filtered.
+	Collections collection.Names
+
+	// RejectUnlabeled indicates that resources without the istio.io/rev label are filtered out. By default, they are
+	// accepted regardless of the revision.
+	RejectUnlabeled bool
+}
+
+// predicateFor returns the revision predicate for the given collection, or nil if it isn't filtered by revision.
+func (o RevisionOptions) predicateFor(c collection.Name) event.Predicate {
+	if o.Revision == "" || !o.filters(c) {
+		return nil
+	}
+
+	matches := event.MatchRevision(o.Revision, !o.RejectUnlabeled, o.Aliases...)
+	return func(c collection.Name, r *resource.Instance) bool {
+		if matches(c, r) {
+			return true
+		}
+		scope.Source.Debugf("Filtering out %s/%s: revision %q does not match",
+			c, r.Metadata.FullName, r.Metadata.Labels[event.RevisionLabel])
+		stats.RecordRevisionSkipped(c.String())
+		return false
+	}
+}
+
+func (o RevisionOptions) filters(c collection.Name) bool {
+	if len(o.Collections) == 0 {
+		return strings.HasPrefix(c.String(), "istio/")
+	}
+	for _, n := range o.Collections {
+		if n == c {
+			return true
+		}
+	}
+	return false
+}
+
+// log the revision filtering configuration.
+func (o RevisionOptions) log() {
+	if o.Revision == "" {
+		return
+	}
+
+	var collections interface{} = "istio/*"
+	if len(o.Collections) > 0 {
+		collections = o.Collections
+	}
+	scope.Source.Infof("Filtering resources by revision %q (aliases: %v, collections: %v, reject unlabeled: %v)",
+		o.Revision, o.Aliases, collections, o.RejectUnlabeled)
+}
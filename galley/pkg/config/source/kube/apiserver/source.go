@@ -21,11 +21,13 @@ import (
 	"sync"
 
 	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	"k8s.io/apimachinery/pkg/labels"
 
 	"istio.io/libistio/galley/pkg/config/scope"
 	"istio.io/libistio/galley/pkg/config/source/kube/rt"
 	"istio.io/libistio/pkg/config/event"
 	"istio.io/libistio/pkg/config/schema/collection"
+	"istio.io/libistio/pkg/config/schema/collections"
 	"istio.io/libistio/pkg/config/schema/resource"
 )
 
@@ -73,11 +75,15 @@ type Source struct { // nolint:maligned
 
 	// watchers for each collection that were created as part of CRD discovery.
 	watchers map[collection.Name]*watcher
+
+	// namespaces watches the namespaced collections, if the watched namespaces are selected by label.
+	namespaces *namespaceController
 }
 
 var _ event.Source = &Source{}
 
-// New returns a new kube.Source.
+// New returns a new kube.Source. If the options are not valid (see Options.Validate), the source fails closed: it
+// doesn't watch any resources, and only publishes a FullSync event for each collection when started.
 func New(o Options) *Source {
 	s := &Source{
 		options:  o,
@@ -104,6 +110,16 @@ func (s *Source) Start() {
 	}
 	s.started = true
 
+	if err := s.options.Validate(); err != nil {
+		// Fail closed: rather than watching more than was asked for, don't watch anything.
+		scope.Source.Errorf("Invalid options, not watching any resources: %v", err)
+		s.mu.Unlock()
+		for _, r := range s.options.Schemas.All() {
+			s.handlers.Handle(event.FullSyncFor(r))
+		}
+		return
+	}
+
 	// Create a set of pending resources. These will be matched up with incoming CRD events for creating watchers for
 	// each resource that we expect.
 	// We also keep track of what resources have been found in the metadata.
@@ -116,11 +132,14 @@ func (s *Source) Start() {
 	// Releasing the lock here to avoid deadlock on crdWatcher between the existing one and a newly started one.
 	s.mu.Unlock()
 
+	s.options.Revision.log()
+
 	// Start the CRD listener. When the listener is fully-synced, the listening of actual resources will start.
 	scope.Source.Infof("Beginning CRD Discovery, to figure out resources that are available...")
-	s.provider = rt.NewProvider(s.options.Client, s.options.WatchedNamespaces, s.options.ResyncPeriod)
+	s.provider = rt.NewProvider(s.options.Client, s.options.WatchedNamespaces, s.options.ResyncPeriod,
+		s.options.providerSelectors()...)
 	a := s.provider.GetAdapter(crdKubeResource.Resource())
-	s.crdWatcher = newWatcher(crdKubeResource, a)
+	s.crdWatcher = newWatcher(crdKubeResource, a, nil)
 	s.crdWatcher.dispatch(event.HandlerFromFn(s.onCrdEvent))
 	s.crdWatcher.start()
 }
@@ -185,6 +204,8 @@ func (s *Source) startWatchers() {
 
 	scope.Source.Info("Creating watchers for Kubernetes CRDs")
 	s.watchers = make(map[collection.Name]*watcher)
+	var namespaced []collection.Schema
+	nsSelector := s.namespaceSelector()
 	for i, r := range resources {
 		a := s.provider.GetAdapter(r.Resource())
 
@@ -204,8 +225,10 @@ func (s *Source) startWatchers() {
 		if (!a.IsBuiltIn() && !found) || r.IsDisabled() {
 			scope.Source.Debuga("Source.Start: sending immediate FullSync for: ", r.Name())
 			s.handlers.Handle(event.FullSyncFor(r))
+		} else if nsSelector != nil && !r.Resource().IsClusterScoped() {
+			namespaced = append(namespaced, r)
 		} else {
-			col := newWatcher(r, a)
+			col := newWatcher(r, a, s.options.Revision.predicateFor(r.Name()))
 			col.dispatch(s.handlers)
 			s.watchers[r.Name()] = col
 		}
@@ -215,6 +238,33 @@ func (s *Source) startWatchers() {
 		scope.Source.Debuga("Source.Start: starting watcher: ", c)
 		w.start()
 	}
+
+	if len(namespaced) > 0 {
+		selectors := s.options.providerSelectors()
+		s.namespaces = newNamespaceController(nsSelector, namespaced, s.options.Revision, s.handlers,
+			func(namespace string) *rt.Provider {
+				return rt.NewProvider(s.options.Client, namespace, s.options.ResyncPeriod, selectors...)
+			})
+		// The namespace controller gets its own provider, and therefore its own Namespace informer: the informers of a
+		// provider are shared, and the Namespaces collection may also be watched through s.provider.
+		nsProvider := rt.NewProvider(s.options.Client, s.options.WatchedNamespaces, s.options.ResyncPeriod, selectors...)
+		s.namespaces.start(nsProvider.GetAdapter(collections.K8SCoreV1Namespaces.Resource()))
+	}
+}
+
+// namespaceSelector parses Options.NamespaceSelector. Returns nil if the selector is not set. An invalid selector
+// (which Options.Validate rejects) selects no namespaces.
+func (s *Source) namespaceSelector() labels.Selector {
+	if s.options.NamespaceSelector == "" {
+		return nil
+	}
+	selector, err := labels.Parse(s.options.NamespaceSelector)
+	if err != nil {
+		scope.Source.Errorf("Invalid namespace selector %q, not selecting any namespace: %v",
+			s.options.NamespaceSelector, err)
+		return labels.Nothing()
+	}
+	return selector
 }
 
 // Stop implements processor.Source
@@ -241,6 +291,11 @@ func (s *Source) stop() {
 		s.watchers = nil
 	}
 
+	if s.namespaces != nil {
+		s.namespaces.stop()
+		s.namespaces = nil
+	}
+
 	if s.crdWatcher != nil {
 		s.crdWatcher.stop()
 		s.crdWatcher = nil
@@ -29,6 +29,7 @@ const (
 	group      = "group"
 	kind       = "kind"
 	errorStr   = "error"
+	collection = "collection"
 )
 
 var (
@@ -40,6 +41,8 @@ var (
 	KindTag tag.Key
 	// ErrorTag holds the error message of a handleEvent failure.
 	ErrorTag tag.Key
+	// CollectionTag holds the collection of the resource.
+	CollectionTag tag.Key
 )
 
 var (
@@ -51,6 +54,10 @@ var (
 		"galley/source/kube/event_success_total",
 		"The number of times a kubernetes source successfully handled an event",
 		stats.UnitDimensionless)
+	sourceRevisionSkipped = stats.Int64(
+		"galley/source/kube/revision_skipped_total",
+		"The number of times a kubernetes source skipped an event for a resource of another revision",
+		stats.UnitDimensionless)
 
 	sourceConversionSuccess = stats.Int64(
 		"galley/source/kube/dynamic/converter_success_total",
@@ -77,6 +84,16 @@ func RecordEventSuccess() {
 	stats.Record(context.Background(), sourceEventSuccess.M(1))
 }
 
+// RecordRevisionSkipped records skipping a kube event, as the resource doesn't belong to the configured revision.
+func RecordRevisionSkipped(col string) {
+	ctx, ctxErr := tag.New(context.Background(), tag.Insert(CollectionTag, col))
+	if ctxErr != nil {
+		scope.Source.Errorf("error creating context to record revision skip")
+	} else {
+		stats.Record(ctx, sourceRevisionSkipped.M(1))
+	}
+}
+
 func newTagKey(label string) tag.Key {
 	if t, err := tag.NewKey(label); err != nil {
 		panic(err)
@@ -100,14 +117,17 @@ func init() {
 	GroupTag = newTagKey(group)
 	KindTag = newTagKey(kind)
 	ErrorTag = newTagKey(errorStr)
+	CollectionTag = newTagKey(collection)
 
 	errorKey := []tag.Key{ErrorTag}
 	conversionKeys := []tag.Key{APIVersionTag, GroupTag, KindTag}
+	collectionKey := []tag.Key{CollectionTag}
 	var noKeys []tag.Key
 
 	err := view.Register(
 		newView(sourceEventError, errorKey, view.Count()),
 		newView(sourceEventSuccess, noKeys, view.Count()),
+		newView(sourceRevisionSkipped, collectionKey, view.Count()),
 		newView(sourceConversionSuccess, conversionKeys, view.Count()),
 		newView(sourceConversionFailure, conversionKeys, view.Count()),
 	)
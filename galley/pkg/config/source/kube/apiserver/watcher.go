@@ -15,8 +15,6 @@
 package apiserver
 
 import (
-	"os"
-	"strings"
 	"sync"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -39,25 +37,23 @@ type watcher struct {
 	handler event.Handler
 
 	done chan struct{}
-	rev  string
-	crds map[string]string
+
+	// filter is used to filter resources by revision. nil if the collection is not filtered.
+	filter *event.Filter
 }
 
-func newWatcher(r collection.Schema, a *rt.Adapter) *watcher {
-	rev := os.Getenv("istio-revision")
-	crds := os.Getenv("rev-crds")
-	items := strings.Split(crds, ",")
-	existCrds := make(map[string]string, 0)
-	for _, item := range items {
-		existCrds[item] = item
-	}
-	return &watcher{
+func newWatcher(r collection.Schema, a *rt.Adapter, revision event.Predicate) *watcher {
+	w := &watcher{
 		schema:  r,
 		adapter: a,
 		handler: event.SentinelHandler(),
-		rev:     rev,
-		crds:    existCrds,
 	}
+	if revision != nil {
+		w.filter = event.NewFilter(event.HandlerFromFn(func(e event.Event) {
+			w.handler.Handle(e)
+		}), revision)
+	}
+	return w
 }
 
 func (w *watcher) start() {
@@ -134,29 +130,6 @@ func (w *watcher) handleEvent(c event.Kind, obj interface{}) {
 		return
 	}
 	r := rt.ToResource(object, w.schema, res, nil)
-	skip := false
-	_, exist := w.crds[r.Metadata.Schema.Plural()]
-	if exist {
-		// empty watcher rev: accept all
-		// non-empty watcher rev: accept resource with empty rev or same rev
-		if w.rev != "" && r.Metadata.Labels != nil {
-			rRev := r.Metadata.Labels["istio.io/rev"]
-			if rRev != "" && rRev != w.rev {
-				skip = true
-				scope.Source.Warnf("istio.io/rev not match skip resource: %v", r.Metadata.FullName)
-			}
-		}
-	}
-	if skip {
-		switch c {
-		case event.Updated:
-			c = event.Deleted
-		case event.Deleted:
-
-		default:
-			return
-		}
-	}
 
 	e := event.Event{
 		Kind:     c,
@@ -164,7 +137,11 @@ func (w *watcher) handleEvent(c event.Kind, obj interface{}) {
 		Resource: r,
 	}
 
-	w.handler.Handle(e)
+	if w.filter != nil {
+		w.filter.Handle(e)
+	} else {
+		w.handler.Handle(e)
+	}
 
 	stats.RecordEventSuccess()
 }
@@ -73,6 +73,8 @@ func (p *Provider) getDynamicAdapter(r resource.Schema) *Adapter {
 				}
 			})
 
+			mlw = p.selected(asTypesKey(r.Group(), r.Kind()), mlw)
+
 			informer := cache.NewSharedIndexInformer(mlw, &unstructured.Unstructured{}, p.resyncPeriod,
 				cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
 
@@ -28,6 +28,7 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
 	"k8s.io/apimachinery/pkg/watch"
+	coreinformers "k8s.io/client-go/informers/core/v1"
 	"k8s.io/client-go/tools/cache"
 
 	"istio.io/libistio/galley/pkg/config/scope"
@@ -67,6 +68,8 @@ func (p *Provider) initKnownAdapters() {
 						}
 					})
 
+				mlw = p.selected(asTypesKey("", "Service"), mlw)
+
 				informer := cache.NewSharedIndexInformer(mlw, &v1.Service{}, p.resyncPeriod,
 					cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
 
@@ -95,6 +98,14 @@ func (p *Provider) initKnownAdapters() {
 				return nil, fmt.Errorf("unable to convert to v1.Namespace: %T", o)
 			},
 			newInformer: func() (cache.SharedIndexInformer, error) {
+				if tweak := p.tweakListOptions(asTypesKey("", "Namespace")); tweak != nil {
+					client, err := p.interfaces.KubeClient()
+					if err != nil {
+						return nil, err
+					}
+					return coreinformers.NewFilteredNamespaceInformer(client, p.resyncPeriod, cache.Indexers{}, tweak), nil
+				}
+
 				informer, err := p.sharedInformerFactory()
 				if err != nil {
 					return nil, err
@@ -125,6 +136,14 @@ func (p *Provider) initKnownAdapters() {
 				return nil, fmt.Errorf("unable to convert to v1.Node: %T", o)
 			},
 			newInformer: func() (cache.SharedIndexInformer, error) {
+				if tweak := p.tweakListOptions(asTypesKey("", "Node")); tweak != nil {
+					client, err := p.interfaces.KubeClient()
+					if err != nil {
+						return nil, err
+					}
+					return coreinformers.NewFilteredNodeInformer(client, p.resyncPeriod, cache.Indexers{}, tweak), nil
+				}
+
 				informer, err := p.sharedInformerFactory()
 				if err != nil {
 					return nil, err
@@ -172,6 +191,8 @@ func (p *Provider) initKnownAdapters() {
 						}
 					})
 
+				mlw = p.selected(asTypesKey("", "Pod"), mlw)
+
 				informer := cache.NewSharedIndexInformer(mlw, &v1.Pod{}, p.resyncPeriod,
 					cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
 
@@ -217,6 +238,8 @@ func (p *Provider) initKnownAdapters() {
 						}
 					})
 
+				mlw = p.selected(asTypesKey("", "Secret"), mlw)
+
 				informer := cache.NewSharedIndexInformer(mlw, &v1.Secret{}, p.resyncPeriod,
 					cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
 
@@ -261,6 +284,8 @@ func (p *Provider) initKnownAdapters() {
 						}
 					})
 
+				mlw = p.selected(asTypesKey("", "Endpoints"), mlw)
+
 				informer := cache.NewSharedIndexInformer(mlw, &v1.Endpoints{}, p.resyncPeriod,
 					cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
 
@@ -317,6 +342,8 @@ func (p *Provider) initKnownAdapters() {
 						}
 					})
 
+				mlw = p.selected(asTypesKey("extensions", "Ingress"), mlw)
+
 				informer := cache.NewSharedIndexInformer(mlw, &v1beta1.Ingress{}, p.resyncPeriod,
 					cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
 
@@ -346,15 +373,17 @@ func (p *Provider) initKnownAdapters() {
 				if err != nil {
 					return nil, err
 				}
-				inf := cache.NewSharedIndexInformer(
-					&cache.ListWatch{
-						ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
-							return ext.ApiextensionsV1beta1().CustomResourceDefinitions().List(context.TODO(), options)
-						},
-						WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
-							return ext.ApiextensionsV1beta1().CustomResourceDefinitions().Watch(context.TODO(), options)
-						},
+				lw := p.selected(asTypesKey("apiextensions.k8s.io", "CustomResourceDefinition"), &cache.ListWatch{
+					ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+						return ext.ApiextensionsV1beta1().CustomResourceDefinitions().List(context.TODO(), options)
 					},
+					WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+						return ext.ApiextensionsV1beta1().CustomResourceDefinitions().Watch(context.TODO(), options)
+					},
+				})
+
+				inf := cache.NewSharedIndexInformer(
+					lw,
 					&v1beta12.CustomResourceDefinition{},
 					0,
 					cache.Indexers{})
@@ -400,6 +429,8 @@ func (p *Provider) initKnownAdapters() {
 						}
 					})
 
+				mlw = p.selected(asTypesKey("apps", "Deployment"), mlw)
+
 				informer := cache.NewSharedIndexInformer(mlw, &appsv1.Deployment{}, p.resyncPeriod,
 					cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
 
@@ -443,6 +474,8 @@ func (p *Provider) initKnownAdapters() {
 						}
 					})
 
+				mlw = p.selected(asTypesKey("", "ConfigMap"), mlw)
+
 				informer := cache.NewSharedIndexInformer(mlw, &v1.ConfigMap{}, p.resyncPeriod,
 					cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
 
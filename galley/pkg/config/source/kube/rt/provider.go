@@ -45,18 +45,26 @@ type Provider struct {
 	resyncPeriod time.Duration
 	interfaces   kube.Interfaces
 	namespaces   []string
+	selectors    map[string]Selector
 	known        map[string]*Adapter
 
 	informers        informers.SharedInformerFactory
 	dynamicInterface dynamic.Interface
 }
 
-// NewProvider returns a new instance of Provider.
-func NewProvider(interfaces kube.Interfaces, namespaces string, resyncPeriod time.Duration) *Provider {
+// NewProvider returns a new instance of Provider. The given selectors scope the objects that are listed and watched
+// for their resource types.
+func NewProvider(interfaces kube.Interfaces, namespaces string, resyncPeriod time.Duration,
+	selectors ...Selector) *Provider {
 	p := &Provider{
 		resyncPeriod: resyncPeriod,
 		interfaces:   interfaces,
 		namespaces:   strings.Split(namespaces, ","),
+		selectors:    make(map[string]Selector, len(selectors)),
+	}
+
+	for _, s := range selectors {
+		p.selectors[asTypesKey(s.Resource.Group(), s.Resource.Kind())] = s
 	}
 
 	p.initKnownAdapters()
@@ -0,0 +1,112 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rt
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+
+	"istio.io/libistio/pkg/config/schema/resource"
+)
+
+// Selector scopes the objects of a resource type that are listed and watched from the API server.
+type Selector struct {
+	// Resource that the selector applies to.
+	Resource resource.Schema
+
+	// Label selector, in the format accepted by labels.Parse. Empty selects everything.
+	Label string
+
+	// Field selector, in the format accepted by fields.ParseSelector. Empty selects everything.
+	Field string
+}
+
+// Validate the label and field selectors.
+func (s Selector) Validate() error {
+	if _, err := labels.Parse(s.Label); err != nil {
+		return fmt.Errorf("invalid label selector for %v: %v", s.Resource.GroupVersionKind(), err)
+	}
+	if _, err := fields.ParseSelector(s.Field); err != nil {
+		return fmt.Errorf("invalid field selector for %v: %v", s.Resource.GroupVersionKind(), err)
+	}
+	return nil
+}
+
+// apply the selector to the given list options. Selectors that are already set in the options are preserved.
+func (s Selector) apply(options *metav1.ListOptions) {
+	options.LabelSelector = joinSelectors(options.LabelSelector, s.Label)
+	options.FieldSelector = joinSelectors(options.FieldSelector, s.Field)
+}
+
+func joinSelectors(s1, s2 string) string {
+	switch {
+	case s1 == "":
+		return s2
+	case s2 == "":
+		return s1
+	default:
+		return s1 + "," + s2
+	}
+}
+
+// selectorFor returns the Selector for the given resource type key, if any.
+func (p *Provider) selectorFor(key string) (Selector, bool) {
+	s, ok := p.selectors[key]
+	return s, ok
+}
+
+// tweakListOptions returns a function that applies the Selector for the given resource type key, if any.
+func (p *Provider) tweakListOptions(key string) func(*metav1.ListOptions) {
+	s, ok := p.selectorFor(key)
+	if !ok {
+		return nil
+	}
+	return s.apply
+}
+
+// selected wraps the given ListerWatcher, so that the Selector for the given resource type key, if any, is applied
+// to its list and watch calls.
+func (p *Provider) selected(key string, lw cache.ListerWatcher) cache.ListerWatcher {
+	s, ok := p.selectorFor(key)
+	if !ok {
+		return lw
+	}
+	return &selectedListerWatcher{lw: lw, selector: s}
+}
+
+type selectedListerWatcher struct {
+	lw       cache.ListerWatcher
+	selector Selector
+}
+
+var _ cache.ListerWatcher = &selectedListerWatcher{}
+
+// List implements cache.Lister
+func (w *selectedListerWatcher) List(options metav1.ListOptions) (runtime.Object, error) {
+	w.selector.apply(&options)
+	return w.lw.List(options)
+}
+
+// Watch implements cache.Watcher
+func (w *selectedListerWatcher) Watch(options metav1.ListOptions) (watch.Interface, error) {
+	w.selector.apply(&options)
+	return w.lw.Watch(options)
+}
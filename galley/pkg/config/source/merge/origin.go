@@ -0,0 +1,57 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merge
+
+import (
+	"istio.io/libistio/pkg/config/resource"
+)
+
+// Origin is a resource.Origin that records which input of a merging Source supplied a resource. The original
+// Origin of the resource, if any, is embedded.
+type Origin struct {
+	resource.Origin
+
+	// Source is the name of the input that supplied the resource.
+	Source string
+
+	// Priority of the input that supplied the resource.
+	Priority int
+}
+
+var _ resource.Origin = &Origin{}
+
+// FriendlyName implements resource.Origin
+func (o *Origin) FriendlyName() string {
+	if o.Origin == nil {
+		return o.Source
+	}
+	return o.Origin.FriendlyName()
+}
+
+// Namespace implements resource.Origin
+func (o *Origin) Namespace() resource.Namespace {
+	if o.Origin == nil {
+		return ""
+	}
+	return o.Origin.Namespace()
+}
+
+// Reference implements resource.Origin
+func (o *Origin) Reference() resource.Reference {
+	if o.Origin == nil {
+		return nil
+	}
+	return o.Origin.Reference()
+}
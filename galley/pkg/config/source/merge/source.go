@@ -0,0 +1,278 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merge
+
+import (
+	"sync"
+
+	"istio.io/libistio/galley/pkg/config/scope"
+	"istio.io/libistio/pkg/config/event"
+	"istio.io/libistio/pkg/config/resource"
+	"istio.io/libistio/pkg/config/schema/collection"
+)
+
+// Input is an underlying source of a merging Source.
+type Input struct {
+	// Name of the input, recorded in the Origin of the resources it supplies.
+	Name string
+
+	// Priority of the input. When more than one input supplies a resource with the same name in the same
+	// collection, the copy from the input with the highest priority is used. Ties are broken in favor of the input
+	// that was specified first.
+	Priority int
+
+	Source event.Source
+
+	// Collections that the input supplies. A FullSync event for a collection is only sent once all the inputs that
+	// supply it have sent theirs.
+	Collections collection.Schemas
+}
+
+// Source is an event.Source that merges the collections of multiple inputs. For every resource, only the copy from
+// the highest priority input is published, and the Origin of published resources is an *Origin that identifies the
+// input. Updated and Deleted events are generated as higher priority copies appear and disappear, so that the
+// downstream handlers see a consistent view of the merged collections.
+//
+// A Reset event from any input is passed through, and clears the merged state.
+type Source struct {
+	inputs []Input
+
+	mu      sync.Mutex
+	handler event.Handler
+	started bool
+
+	// collection -> resource name -> the copies supplied by each input, by input index
+	copies map[collection.Name]map[resource.FullName]map[int]*resource.Instance
+
+	// collection -> resource name -> the index of the input whose copy was published
+	published map[collection.Name]map[resource.FullName]int
+
+	// collection -> the indices of the inputs that haven't sent FullSync yet
+	pendingSync map[collection.Name]map[int]struct{}
+}
+
+var _ event.Source = &Source{}
+
+// New returns a new merging Source for the given inputs.
+func New(inputs ...Input) *Source {
+	s := &Source{
+		inputs:  append([]Input(nil), inputs...),
+		handler: event.SentinelHandler(),
+	}
+	s.reset()
+
+	for i := range s.inputs {
+		s.inputs[i].Source.Dispatch(&inputHandler{source: s, index: i})
+	}
+	return s
+}
+
+// must be called with lock held
+func (s *Source) reset() {
+	s.copies = make(map[collection.Name]map[resource.FullName]map[int]*resource.Instance)
+	s.published = make(map[collection.Name]map[resource.FullName]int)
+	s.pendingSync = make(map[collection.Name]map[int]struct{})
+	for i, in := range s.inputs {
+		for _, c := range in.Collections.All() {
+			pending, ok := s.pendingSync[c.Name()]
+			if !ok {
+				pending = make(map[int]struct{})
+				s.pendingSync[c.Name()] = pending
+			}
+			pending[i] = struct{}{}
+		}
+	}
+}
+
+// Dispatch implements event.Source
+func (s *Source) Dispatch(h event.Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.handler = event.CombineHandlers(s.handler, h)
+}
+
+// Start implements event.Source
+func (s *Source) Start() {
+	s.mu.Lock()
+	if s.started {
+		s.mu.Unlock()
+		return
+	}
+	s.started = true
+	s.reset()
+	s.mu.Unlock()
+
+	// The inputs are started without holding the lock, as they may dispatch events synchronously.
+	for _, in := range s.inputs {
+		in.Source.Start()
+	}
+}
+
+// Stop implements event.Source
+func (s *Source) Stop() {
+	s.mu.Lock()
+	if !s.started {
+		s.mu.Unlock()
+		return
+	}
+	s.started = false
+	s.mu.Unlock()
+
+	for _, in := range s.inputs {
+		in.Source.Stop()
+	}
+}
+
+type inputHandler struct {
+	source *Source
+	index  int
+}
+
+var _ event.Handler = &inputHandler{}
+
+// Handle implements event.Handler
+func (h *inputHandler) Handle(e event.Event) {
+	h.source.handle(h.index, e)
+}
+
+func (s *Source) handle(i int, e event.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.started {
+		return
+	}
+
+	switch e.Kind {
+	case event.Reset:
+		scope.Source.Infof("merge.Source: reset from input %q", s.inputs[i].Name)
+		s.reset()
+		s.handler.Handle(e)
+
+	case event.FullSync:
+		c := e.SourceName()
+		pending := s.pendingSync[c]
+		if _, ok := pending[i]; !ok {
+			return
+		}
+		delete(pending, i)
+		if len(pending) == 0 {
+			s.handler.Handle(e)
+		}
+
+	case event.Added, event.Updated:
+		c := e.SourceName()
+		byName, ok := s.copies[c]
+		if !ok {
+			byName = make(map[resource.FullName]map[int]*resource.Instance)
+			s.copies[c] = byName
+		}
+		name := e.Resource.Metadata.FullName
+		copies, ok := byName[name]
+		if !ok {
+			copies = make(map[int]*resource.Instance)
+			byName[name] = copies
+		}
+		copies[i] = e.Resource
+		s.publish(e.Source, name, i)
+
+	case event.Deleted:
+		c := e.SourceName()
+		name := e.Resource.Metadata.FullName
+		copies := s.copies[c][name]
+		if _, ok := copies[i]; !ok {
+			return
+		}
+		delete(copies, i)
+		if len(copies) > 0 {
+			s.publish(e.Source, name, i)
+			return
+		}
+
+		// The last copy is gone. Since the published copy always comes from an input that still has one, this
+		// was the published copy.
+		delete(s.copies[c], name)
+		delete(s.published[c], name)
+		s.handler.Handle(event.Event{
+			Kind:     event.Deleted,
+			Source:   e.Source,
+			Resource: s.withOrigin(i, e.Resource),
+		})
+
+	default:
+		scope.Source.Warnf("merge.Source: unrecognized event from input %q: %v", s.inputs[i].Name, e)
+	}
+}
+
+// publish sends the events needed to bring the published copy of the named resource up to date, after the copy
+// from input i has been added, updated or deleted. There must be at least one copy left. Must be called with lock
+// held.
+func (s *Source) publish(c collection.Schema, name resource.FullName, i int) {
+	published, ok := s.published[c.Name()]
+	if !ok {
+		published = make(map[resource.FullName]int)
+		s.published[c.Name()] = published
+	}
+	prev, wasPublished := published[name]
+
+	copies := s.copies[c.Name()][name]
+	winner, _ := s.winner(copies)
+	if wasPublished && winner == prev && winner != i {
+		// A lower priority copy changed. The published copy is unaffected.
+		return
+	}
+
+	kind := event.Updated
+	if !wasPublished {
+		kind = event.Added
+	}
+	if wasPublished && winner != prev {
+		scope.Source.Debugf("merge.Source: %v/%v is now supplied by input %q (was %q)",
+			c.Name(), name, s.inputs[winner].Name, s.inputs[prev].Name)
+	}
+	published[name] = winner
+	s.handler.Handle(event.Event{
+		Kind:     kind,
+		Source:   c,
+		Resource: s.withOrigin(winner, copies[winner]),
+	})
+}
+
+// winner returns the index of the input with the highest priority among the given copies.
+func (s *Source) winner(copies map[int]*resource.Instance) (int, bool) {
+	winner := -1
+	for i := range copies {
+		if winner == -1 {
+			winner = i
+			continue
+		}
+		pi, pw := s.inputs[i].Priority, s.inputs[winner].Priority
+		if pi > pw || (pi == pw && i < winner) {
+			winner = i
+		}
+	}
+	return winner, winner != -1
+}
+
+func (s *Source) withOrigin(i int, r *resource.Instance) *resource.Instance {
+	result := *r
+	result.Origin = &Origin{
+		Origin:   r.Origin,
+		Source:   s.inputs[i].Name,
+		Priority: s.inputs[i].Priority,
+	}
+	return &result
+}
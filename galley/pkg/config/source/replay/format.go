@@ -0,0 +1,287 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	protoio "github.com/gogo/protobuf/io"
+	"github.com/gogo/protobuf/jsonpb"
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/types"
+
+	mcp "istio.io/api/mcp/v1alpha1"
+
+	"istio.io/libistio/pkg/config/event"
+)
+
+// Format is the encoding of a recorded event stream.
+type Format int
+
+const (
+	// JSONLines encodes each event as a single line of JSON. Resources are encoded with jsonpb.
+	JSONLines Format = iota
+
+	// Protobuf encodes each event as a length-delimited recordProto message, preceded by a magic header.
+	Protobuf
+)
+
+// String implements fmt.Stringer
+func (f Format) String() string {
+	switch f {
+	case JSONLines:
+		return "JSONLines"
+	case Protobuf:
+		return "Protobuf"
+	default:
+		return fmt.Sprintf("<<Unknown Format %d>>", f)
+	}
+}
+
+var protobufMagic = []byte("CFGEVTS1")
+
+// maxRecordSize is the largest single record accepted when reading a protobuf recording.
+const maxRecordSize = 64 * 1024 * 1024
+
+// record is a single recorded event, in its serialized form.
+type record struct {
+	time       time.Time
+	kind       event.Kind
+	collection string
+	resource   *mcp.Resource
+}
+
+// recordProto is the protobuf encoding of a record. The field numbers are part of the recording format, and must
+// not be changed.
+type recordProto struct {
+	Kind       string           `protobuf:"bytes,1,opt,name=kind,proto3" json:"kind,omitempty"`
+	Collection string           `protobuf:"bytes,2,opt,name=collection,proto3" json:"collection,omitempty"`
+	Time       *types.Timestamp `protobuf:"bytes,3,opt,name=time,proto3" json:"time,omitempty"`
+	Resource   *mcp.Resource    `protobuf:"bytes,4,opt,name=resource,proto3" json:"resource,omitempty"`
+}
+
+var _ proto.Message = &recordProto{}
+
+// Reset implements proto.Message
+func (m *recordProto) Reset() { *m = recordProto{} }
+
+// String implements proto.Message
+func (m *recordProto) String() string { return proto.CompactTextString(m) }
+
+// ProtoMessage implements proto.Message
+func (*recordProto) ProtoMessage() {}
+
+type jsonRecord struct {
+	Time       time.Time       `json:"time"`
+	Kind       string          `json:"kind"`
+	Collection string          `json:"collection,omitempty"`
+	Resource   json.RawMessage `json:"resource,omitempty"`
+}
+
+var kinds = map[string]event.Kind{
+	event.Added.String():    event.Added,
+	event.Updated.String():  event.Updated,
+	event.Deleted.String():  event.Deleted,
+	event.FullSync.String(): event.FullSync,
+	event.Reset.String():    event.Reset,
+}
+
+func parseKind(s string) (event.Kind, error) {
+	k, ok := kinds[s]
+	if !ok {
+		return event.None, fmt.Errorf("unknown event kind: %q", s)
+	}
+	return k, nil
+}
+
+type encoder interface {
+	encode(r record) error
+	flush() error
+}
+
+type decoder interface {
+	// decode returns io.EOF when there are no more records.
+	decode() (record, error)
+}
+
+func newEncoder(w io.Writer, f Format) (encoder, error) {
+	bw := bufio.NewWriter(w)
+	switch f {
+	case JSONLines:
+		return &jsonEncoder{w: bw}, nil
+	case Protobuf:
+		if _, err := bw.Write(protobufMagic); err != nil {
+			return nil, err
+		}
+		return &protoEncoder{w: bw, dw: protoio.NewDelimitedWriter(bw)}, nil
+	default:
+		return nil, fmt.Errorf("unknown format: %v", f)
+	}
+}
+
+func newDecoder(r io.Reader, f Format) (decoder, error) {
+	br := bufio.NewReader(r)
+	switch f {
+	case JSONLines:
+		return &jsonDecoder{s: newLineScanner(br)}, nil
+	case Protobuf:
+		magic := make([]byte, len(protobufMagic))
+		if _, err := io.ReadFull(br, magic); err != nil {
+			return nil, fmt.Errorf("error reading recording header: %v", err)
+		}
+		if !bytes.Equal(magic, protobufMagic) {
+			return nil, errors.New("not a recorded event stream")
+		}
+		return &protoDecoder{dr: protoio.NewDelimitedReader(br, maxRecordSize)}, nil
+	default:
+		return nil, fmt.Errorf("unknown format: %v", f)
+	}
+}
+
+type jsonEncoder struct {
+	w *bufio.Writer
+	m jsonpb.Marshaler
+}
+
+func (e *jsonEncoder) encode(r record) error {
+	jr := jsonRecord{
+		Time:       r.time,
+		Kind:       r.kind.String(),
+		Collection: r.collection,
+	}
+	if r.resource != nil {
+		s, err := e.m.MarshalToString(r.resource)
+		if err != nil {
+			return err
+		}
+		jr.Resource = json.RawMessage(s)
+	}
+
+	b, err := json.Marshal(jr)
+	if err != nil {
+		return err
+	}
+	if _, err = e.w.Write(b); err != nil {
+		return err
+	}
+	return e.w.WriteByte('\n')
+}
+
+func (e *jsonEncoder) flush() error {
+	return e.w.Flush()
+}
+
+func newLineScanner(r io.Reader) *bufio.Scanner {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), maxRecordSize)
+	return s
+}
+
+type jsonDecoder struct {
+	s    *bufio.Scanner
+	line int
+}
+
+func (d *jsonDecoder) decode() (record, error) {
+	for d.s.Scan() {
+		d.line++
+		b := bytes.TrimSpace(d.s.Bytes())
+		if len(b) == 0 {
+			continue
+		}
+
+		var jr jsonRecord
+		if err := json.Unmarshal(b, &jr); err != nil {
+			return record{}, fmt.Errorf("line %d: %v", d.line, err)
+		}
+		k, err := parseKind(jr.Kind)
+		if err != nil {
+			return record{}, fmt.Errorf("line %d: %v", d.line, err)
+		}
+
+		r := record{
+			time:       jr.Time,
+			kind:       k,
+			collection: jr.Collection,
+		}
+		if len(jr.Resource) > 0 {
+			r.resource = &mcp.Resource{}
+			if err = jsonpb.Unmarshal(bytes.NewReader(jr.Resource), r.resource); err != nil {
+				return record{}, fmt.Errorf("line %d: %v", d.line, err)
+			}
+		}
+		return r, nil
+	}
+
+	if err := d.s.Err(); err != nil {
+		return record{}, err
+	}
+	return record{}, io.EOF
+}
+
+type protoEncoder struct {
+	w  *bufio.Writer
+	dw protoio.WriteCloser
+}
+
+func (e *protoEncoder) encode(r record) error {
+	t, err := types.TimestampProto(r.time)
+	if err != nil {
+		return err
+	}
+	return e.dw.WriteMsg(&recordProto{
+		Kind:       r.kind.String(),
+		Collection: r.collection,
+		Time:       t,
+		Resource:   r.resource,
+	})
+}
+
+func (e *protoEncoder) flush() error {
+	return e.w.Flush()
+}
+
+type protoDecoder struct {
+	dr protoio.ReadCloser
+}
+
+func (d *protoDecoder) decode() (record, error) {
+	m := &recordProto{}
+	if err := d.dr.ReadMsg(m); err != nil {
+		return record{}, err
+	}
+
+	k, err := parseKind(m.Kind)
+	if err != nil {
+		return record{}, err
+	}
+	t, err := types.TimestampFromProto(m.Time)
+	if err != nil {
+		return record{}, fmt.Errorf("invalid record time: %v", err)
+	}
+
+	return record{
+		time:       t,
+		kind:       k,
+		collection: m.Collection,
+		resource:   m.Resource,
+	}, nil
+}
@@ -0,0 +1,87 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replay
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"istio.io/libistio/galley/pkg/config/scope"
+	"istio.io/libistio/pkg/config/event"
+	"istio.io/libistio/pkg/config/resource"
+)
+
+// Recorder is an event.Handler that writes every event it receives to an io.Writer, so that the event stream
+// can later be replayed with Source. Writes are buffered; Flush must be called once recording is complete.
+type Recorder struct {
+	mu  sync.Mutex
+	enc encoder
+	err error
+}
+
+var _ event.Handler = &Recorder{}
+
+// NewRecorder returns a new Recorder that writes events to w in the given format.
+func NewRecorder(w io.Writer, f Format) (*Recorder, error) {
+	enc, err := newEncoder(w, f)
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{enc: enc}, nil
+}
+
+// Handle implements event.Handler
+func (r *Recorder) Handle(e event.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err != nil {
+		return
+	}
+
+	rec := record{
+		time:       time.Now(),
+		kind:       e.Kind,
+		collection: e.SourceName().String(),
+	}
+	if e.Resource != nil {
+		res, err := resource.Serialize(e.Resource)
+		if err != nil {
+			scope.Processing.Errorf("Recorder: unable to serialize event %v: %v", e, err)
+			r.err = err
+			return
+		}
+		rec.resource = res
+	}
+
+	if err := r.enc.encode(rec); err != nil {
+		scope.Processing.Errorf("Recorder: unable to record event %v: %v", e, err)
+		r.err = err
+	}
+}
+
+// Flush writes any buffered events to the underlying writer. It returns the first error encountered while
+// recording, if any. Once an error is encountered, subsequent events are dropped.
+func (r *Recorder) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err != nil {
+		return r.err
+	}
+	r.err = r.enc.flush()
+	return r.err
+}
@@ -0,0 +1,203 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replay
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"istio.io/libistio/galley/pkg/config/scope"
+	"istio.io/libistio/pkg/config/event"
+	"istio.io/libistio/pkg/config/resource"
+	"istio.io/libistio/pkg/config/schema/collection"
+)
+
+// Options for a replay Source.
+type Options struct {
+	// Format of the recording.
+	Format Format
+
+	// Speed at which the recording is replayed, relative to the original timing of the events. For example,
+	// a Speed of 2 replays the events twice as fast as they were recorded. If zero, the events are replayed
+	// synchronously, as part of Start, without any delays.
+	Speed float64
+}
+
+// Source is an event.Source that replays a recorded event stream. Every time the source is started, the
+// recording is replayed from the beginning.
+type Source struct {
+	mu      sync.Mutex
+	handler event.Handler
+	events  []timedEvent
+	speed   float64
+
+	started bool
+	stopCh  chan struct{}
+	doneCh  chan struct{}
+}
+
+var _ event.Source = &Source{}
+
+type timedEvent struct {
+	time time.Time
+	e    event.Event
+}
+
+// NewSource reads a recording from r and returns a new Source that replays it. The schemas are used to
+// resolve the collections of the recorded events. The recording is read in its entirety before returning.
+func NewSource(r io.Reader, schemas collection.Schemas, o Options) (*Source, error) {
+	if o.Speed < 0 {
+		return nil, fmt.Errorf("invalid replay speed: %v", o.Speed)
+	}
+
+	dec, err := newDecoder(r, o.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []timedEvent
+	for {
+		rec, err := dec.decode()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading recording: %v", err)
+		}
+
+		e, err := toEvent(rec, schemas)
+		if err != nil {
+			return nil, fmt.Errorf("event %d: %v", len(events), err)
+		}
+		events = append(events, timedEvent{time: rec.time, e: e})
+	}
+
+	scope.Source.Debugf("Creating new replay source (events: %d)", len(events))
+
+	return &Source{
+		handler: event.SentinelHandler(),
+		events:  events,
+		speed:   o.Speed,
+	}, nil
+}
+
+func toEvent(rec record, schemas collection.Schemas) (event.Event, error) {
+	e := event.Event{Kind: rec.kind}
+	if rec.collection == "" {
+		if rec.kind != event.Reset {
+			return event.Event{}, fmt.Errorf("missing collection for %v event", rec.kind)
+		}
+		return e, nil
+	}
+
+	s, ok := schemas.Find(rec.collection)
+	if !ok {
+		return event.Event{}, fmt.Errorf("unknown collection: %q", rec.collection)
+	}
+	e.Source = s
+
+	if rec.resource != nil {
+		r, err := resource.Deserialize(rec.resource, s.Resource())
+		if err != nil {
+			return event.Event{}, err
+		}
+		e.Resource = r
+	}
+	return e, nil
+}
+
+// Dispatch implements event.Source
+func (s *Source) Dispatch(h event.Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.handler = event.CombineHandlers(s.handler, h)
+}
+
+// Start implements event.Source
+func (s *Source) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.started {
+		return
+	}
+	s.started = true
+
+	s.doneCh = make(chan struct{})
+	if s.speed == 0 {
+		for _, te := range s.events {
+			s.handler.Handle(te.e)
+		}
+		close(s.doneCh)
+		return
+	}
+
+	s.stopCh = make(chan struct{})
+	go s.replay(s.handler, s.stopCh, s.doneCh)
+}
+
+func (s *Source) replay(h event.Handler, stopCh, doneCh chan struct{}) {
+	defer close(doneCh)
+
+	for i, te := range s.events {
+		if i > 0 {
+			if d := te.time.Sub(s.events[i-1].time); d > 0 {
+				t := time.NewTimer(time.Duration(float64(d) / s.speed))
+				select {
+				case <-t.C:
+				case <-stopCh:
+					t.Stop()
+					return
+				}
+			}
+		}
+
+		select {
+		case <-stopCh:
+			return
+		default:
+		}
+		h.Handle(te.e)
+	}
+	scope.Source.Debugf("replay.Source: replayed %d events", len(s.events))
+}
+
+// Done returns a channel that is closed once the current replay has completed or has been stopped.
+func (s *Source) Done() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.doneCh
+}
+
+// Stop implements event.Source
+func (s *Source) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.started {
+		return
+	}
+	s.started = false
+
+	if s.stopCh != nil {
+		close(s.stopCh)
+		<-s.doneCh
+		s.stopCh = nil
+	}
+}
@@ -0,0 +1,207 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package kubeexport renders resources as Kubernetes-style YAML documents. It is the reverse of
+// the YAML parsing done by the in-memory Kubernetes source.
+package kubeexport
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/gogo/protobuf/proto"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/libistio/galley/pkg/config/util/kubeyaml"
+	"istio.io/libistio/pkg/config/resource"
+	"istio.io/libistio/pkg/config/schema/collection"
+	"istio.io/libistio/pkg/mcp/snapshot"
+	"istio.io/libistio/pkg/util/gogoprotomarshal"
+)
+
+// Split determines how exported documents are grouped into files.
+type Split int
+
+const (
+	// SplitNone puts all documents in a single file, keyed by the empty string.
+	SplitNone Split = iota
+
+	// SplitByCollection puts the documents of each collection in a separate file, keyed by collection name.
+	SplitByCollection
+
+	// SplitByNamespace puts the documents of each namespace in a separate file, keyed by namespace.
+	// Cluster-scoped resources are keyed by ClusterScopedKey.
+	SplitByNamespace
+)
+
+// ClusterScopedKey is the key of cluster-scoped resources when splitting by namespace.
+const ClusterScopedKey = "_cluster"
+
+// Entry is a resource to export, along with the schema of its collection.
+type Entry struct {
+	Schema   collection.Schema
+	Resource *resource.Instance
+}
+
+// ToYAML renders a single resource as a Kubernetes-style YAML document. The apiVersion and kind
+// are taken from the resource schema, the metadata from the resource metadata, and the spec from
+// the resource message.
+func ToYAML(s collection.Schema, r *resource.Instance) ([]byte, error) {
+	obj, err := toObject(s, r)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(obj)
+}
+
+func toObject(s collection.Schema, r *resource.Instance) (map[string]interface{}, error) {
+	rs := s.Resource()
+
+	metadata := map[string]interface{}{
+		"name": string(r.Metadata.FullName.Name),
+	}
+	if !rs.IsClusterScoped() && r.Metadata.FullName.Namespace != "" {
+		metadata["namespace"] = string(r.Metadata.FullName.Namespace)
+	}
+	if len(r.Metadata.Labels) > 0 {
+		metadata["labels"] = map[string]string(r.Metadata.Labels)
+	}
+	if len(r.Metadata.Annotations) > 0 {
+		metadata["annotations"] = map[string]string(r.Metadata.Annotations)
+	}
+
+	var obj map[string]interface{}
+	if r.Message != nil {
+		body, err := toJSONMap(r.Message)
+		if err != nil {
+			return nil, fmt.Errorf("error converting %s %s to JSON: %v", rs.Kind(), r.Metadata.FullName, err)
+		}
+
+		if _, ok := r.Message.(metav1.Object); ok {
+			// some built-in types carry the whole object rather than just the spec.
+			obj = body
+			delete(obj, "status")
+		} else {
+			obj = map[string]interface{}{
+				"spec": body,
+			}
+		}
+	} else {
+		obj = make(map[string]interface{})
+	}
+
+	obj["apiVersion"] = kubeAPIVersion(rs.Group(), rs.Version())
+	obj["kind"] = rs.Kind()
+	obj["metadata"] = metadata
+
+	return obj, nil
+}
+
+// kubeAPIVersion returns the apiVersion of the group and version. The core group is omitted.
+func kubeAPIVersion(group, version string) string {
+	if group == "" {
+		return version
+	}
+	return group + "/" + version
+}
+
+// toJSONMap converts the message to a generic map. Kubernetes types are encoded with their JSON
+// tags, all other types with the canonical protobuf JSON encoding.
+func toJSONMap(m proto.Message) (map[string]interface{}, error) {
+	t := reflect.TypeOf(m)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if !strings.HasPrefix(t.PkgPath(), "k8s.io/") {
+		return gogoprotomarshal.ToJSONMap(m)
+	}
+
+	js, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	var result map[string]interface{}
+	if err = json.Unmarshal(js, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Export renders the given entries as multi-document YAML, grouped according to split. Documents are
+// ordered by collection and then by resource name.
+func Export(entries []Entry, split Split) (map[string][]byte, error) {
+	sorted := make([]Entry, len(entries))
+	copy(sorted, entries)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		ci, cj := sorted[i].Schema.Name().String(), sorted[j].Schema.Name().String()
+		if ci != cj {
+			return ci < cj
+		}
+		return sorted[i].Resource.Metadata.FullName.String() < sorted[j].Resource.Metadata.FullName.String()
+	})
+
+	parts := make(map[string][][]byte)
+	for _, e := range sorted {
+		doc, err := ToYAML(e.Schema, e.Resource)
+		if err != nil {
+			return nil, err
+		}
+
+		var key string
+		switch split {
+		case SplitByCollection:
+			key = e.Schema.Name().String()
+		case SplitByNamespace:
+			if e.Schema.Resource().IsClusterScoped() || e.Resource.Metadata.FullName.Namespace == "" {
+				key = ClusterScopedKey
+			} else {
+				key = string(e.Resource.Metadata.FullName.Namespace)
+			}
+		}
+		parts[key] = append(parts[key], doc)
+	}
+
+	result := make(map[string][]byte, len(parts))
+	for k, docs := range parts {
+		result[k] = kubeyaml.Join(docs...)
+	}
+	return result, nil
+}
+
+// ExportSnapshot renders the contents of the snapshot as multi-document YAML, grouped according to
+// split. The collections of the snapshot are looked up in schemas. An error is returned if a
+// collection is unknown or one of its resources cannot be deserialized.
+func ExportSnapshot(s snapshot.Snapshot, schemas collection.Schemas, split Split) (map[string][]byte, error) {
+	var entries []Entry
+	for _, name := range s.Collections() {
+		schema, found := schemas.Find(name)
+		if !found {
+			return nil, fmt.Errorf("unknown collection: %s", name)
+		}
+
+		for _, e := range s.Resources(name) {
+			r, err := resource.Deserialize(e, schema.Resource())
+			if err != nil {
+				return nil, fmt.Errorf("error deserializing %s in collection %s: %v", e.Metadata.Name, name, err)
+			}
+			entries = append(entries, Entry{Schema: schema, Resource: r})
+		}
+	}
+
+	return Export(entries, split)
+}
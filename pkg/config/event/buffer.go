@@ -15,18 +15,80 @@
 package event
 
 import (
+	"fmt"
 	"sync"
 
+	"istio.io/pkg/monitoring"
+
 	"istio.io/libistio/galley/pkg/config/scope"
 )
 
-// Buffer is a growing event buffer.
+// OverflowPolicy determines what a bounded Buffer does when an event is added while it is full.
+type OverflowPolicy int
+
+const (
+	// Block the producer until the buffer has room for the event. The buffer must be processed
+	// concurrently, otherwise the producer blocks until the buffer is cleared or stopped.
+	Block OverflowPolicy = iota
+
+	// DropOldest drops the oldest queued resource event to make room for the new event. As the handlers miss the
+	// dropped event, their state is out of sync from then on: a Reset event is queued as soon as the buffer has room
+	// for it, so that they can abandon their state and restart. FullSync and Reset events are never dropped: if the
+	// buffer only holds such events, it collapses to a Reset instead (see CollapseToReset).
+	DropOldest
+
+	// CollapseToReset drops all queued events and replaces them with a single Reset event. Events
+	// added before the Reset event is processed are dropped, as the handlers are expected to
+	// abandon their state and restart.
+	CollapseToReset
+)
+
+// String implements Stringer.String
+func (p OverflowPolicy) String() string {
+	switch p {
+	case Block:
+		return "Block"
+	case DropOldest:
+		return "DropOldest"
+	case CollapseToReset:
+		return "CollapseToReset"
+	default:
+		return fmt.Sprintf("<<Unknown OverflowPolicy %d>>", p)
+	}
+}
+
+// BufferOptions configures a Buffer.
+type BufferOptions struct {
+	// Name of the buffer, used in metrics. Metrics are only recorded for named buffers.
+	Name string
+
+	// Capacity is the maximum number of queued events. Zero means unbounded.
+	Capacity int
+
+	// Overflow is the policy applied when an event is added to a full buffer.
+	Overflow OverflowPolicy
+}
+
+// Buffer is a growing event buffer. It can optionally be bounded, see BufferOptions.
 type Buffer struct {
 	mu         sync.Mutex
 	queue      queue
 	handler    Handler
 	cond       *sync.Cond
 	processing bool
+	// stopped is set by Stop, and releases producers blocked on a full buffer.
+	stopped bool
+
+	options   BufferOptions
+	collapsed bool
+	highWater int
+	// dropped indicates that events were dropped, and that a Reset event needs to be queued.
+	dropped bool
+
+	// metrics are only set for named buffers.
+	depthMetric     monitoring.Metric
+	highWaterMetric monitoring.Metric
+	overflowMetric  monitoring.Metric
 }
 
 var _ Handler = &Buffer{}
@@ -34,8 +96,21 @@ var _ Dispatcher = &Buffer{}
 
 // NewBuffer returns new Buffer instance
 func NewBuffer() *Buffer {
-	b := &Buffer{}
+	return NewBufferWithOptions(BufferOptions{})
+}
+
+// NewBufferWithOptions returns new Buffer instance with the given options.
+func NewBufferWithOptions(o BufferOptions) *Buffer {
+	b := &Buffer{
+		options: o,
+	}
 	b.cond = sync.NewCond(&b.mu)
+
+	if o.Name != "" {
+		b.depthMetric = bufferDepth.With(bufferTag.Value(o.Name))
+		b.highWaterMetric = bufferHighWaterMark.With(bufferTag.Value(o.Name))
+		b.overflowMetric = bufferOverflowsTotal.With(bufferTag.Value(o.Name), policyTag.Value(o.Overflow.String()))
+	}
 	return b
 }
 
@@ -50,9 +125,79 @@ func WithBuffer(s Dispatcher) *Buffer {
 // Handle implements Handler
 func (b *Buffer) Handle(e Event) {
 	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.collapsed {
+		// the buffer has collapsed to a Reset that is yet to be processed.
+		return
+	}
+
+	if b.options.Capacity > 0 && b.queue.size() >= b.options.Capacity {
+		b.recordOverflow()
+
+		switch b.options.Overflow {
+		case Block:
+			for !b.stopped && b.queue.size() >= b.options.Capacity {
+				b.cond.Wait()
+			}
+			if b.stopped {
+				scope.Processing.Debugf("Buffer.Handle: buffer stopped, dropping event: %v", e)
+				return
+			}
+
+		case DropOldest:
+			dropped, ok := b.queue.removeFirst(func(e Event) bool {
+				return e.Kind != FullSync && e.Kind != Reset
+			})
+			if !ok {
+				// only FullSync and Reset events are queued.
+				b.collapse()
+				return
+			}
+			scope.Processing.Debugf("Buffer.Handle: buffer full, dropping oldest event: %v", dropped)
+			b.dropped = true
+
+		case CollapseToReset:
+			b.collapse()
+			return
+		}
+	}
+
 	b.queue.add(e)
+	b.recordDepth()
 	b.cond.Broadcast()
-	b.mu.Unlock()
+}
+
+// collapse the contents of the buffer to a single Reset event. Must be called with lock held.
+func (b *Buffer) collapse() {
+	scope.Processing.Warnf("Buffer.Handle: buffer full (capacity=%d), collapsing to reset", b.options.Capacity)
+	b.queue.clear()
+	b.queue.add(Event{Kind: Reset})
+	b.collapsed = true
+	b.dropped = false
+	b.recordDepth()
+	b.cond.Broadcast()
+}
+
+// must be called with lock held
+func (b *Buffer) recordDepth() {
+	depth := b.queue.size()
+	if depth > b.highWater {
+		b.highWater = depth
+		if b.highWaterMetric != nil {
+			b.highWaterMetric.Record(float64(depth))
+		}
+	}
+	if b.depthMetric != nil {
+		b.depthMetric.Record(float64(depth))
+	}
+}
+
+// must be called with lock held
+func (b *Buffer) recordOverflow() {
+	if b.overflowMetric != nil {
+		b.overflowMetric.Increment()
+	}
 }
 
 // Dispatch implements Source
@@ -64,13 +209,33 @@ func (b *Buffer) Dispatch(handler Handler) {
 func (b *Buffer) Clear() {
 	b.mu.Lock()
 	b.queue.clear()
+	b.collapsed = false
+	b.dropped = false
+	b.recordDepth()
+	// wake up blocked producers.
+	b.cond.Broadcast()
 	b.mu.Unlock()
 }
 
-// Stop processing
+// Size returns the number of queued events.
+func (b *Buffer) Size() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.queue.size()
+}
+
+// HighWaterMark returns the largest number of events that were queued at once.
+func (b *Buffer) HighWaterMark() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.highWater
+}
+
+// Stop processing. Producers that are blocked on a full buffer are released, and their events are dropped.
 func (b *Buffer) Stop() {
 	b.mu.Lock()
 	b.processing = false
+	b.stopped = true
 	b.cond.Broadcast()
 	b.mu.Unlock()
 }
@@ -83,6 +248,7 @@ func (b *Buffer) Process() {
 		return
 	}
 	b.processing = true
+	b.stopped = false
 
 	for {
 		// lock must be held when entering the for loop (whether from beginning, or through loop continuation).
@@ -99,6 +265,20 @@ func (b *Buffer) Process() {
 			b.cond.Wait()
 			continue
 		}
+		if b.collapsed && e.Kind == Reset && b.queue.isEmpty() {
+			b.collapsed = false
+		}
+		if b.dropped && b.queue.size() < b.options.Capacity {
+			// there is room for the Reset event, now that an event was popped.
+			scope.Processing.Warnf("Buffer.Process: events were dropped, queueing reset")
+			b.queue.add(Event{Kind: Reset})
+			b.dropped = false
+		}
+		b.recordDepth()
+		if b.options.Capacity > 0 {
+			// wake up producers blocked on a full buffer.
+			b.cond.Broadcast()
+		}
 
 		if b.handler != nil {
 			b.mu.Unlock()
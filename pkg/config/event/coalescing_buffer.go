@@ -0,0 +1,202 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package event
+
+import (
+	"sync"
+
+	"istio.io/libistio/galley/pkg/config/scope"
+	"istio.io/libistio/pkg/config/resource"
+	"istio.io/libistio/pkg/config/schema/collection"
+)
+
+// CoalescingBuffer is an event buffer that keeps at most one pending event per resource. Events for a resource
+// that is already pending are merged into the pending event, which keeps its place in the queue:
+//
+// - Added followed by Updated becomes Added.
+// - Added followed by Deleted cancels out, and neither event is delivered.
+// - Updated followed by Updated becomes Updated.
+// - Updated followed by Deleted becomes Deleted.
+// - Deleted followed by Added becomes Updated.
+//
+// FullSync and Reset events act as barriers: events are never merged across them, and they are delivered in
+// the order they were received, relative to the resource events.
+type CoalescingBuffer struct {
+	mu         sync.Mutex
+	items      []*coalescedItem
+	pending    map[coalescingKey]*coalescedItem
+	handler    Handler
+	cond       *sync.Cond
+	processing bool
+}
+
+var _ Handler = &CoalescingBuffer{}
+var _ Dispatcher = &CoalescingBuffer{}
+
+type coalescingKey struct {
+	collection collection.Name
+	name       resource.FullName
+}
+
+type coalescedItem struct {
+	e       Event
+	removed bool
+}
+
+// NewCoalescingBuffer returns new CoalescingBuffer instance
+func NewCoalescingBuffer() *CoalescingBuffer {
+	b := &CoalescingBuffer{
+		pending: make(map[coalescingKey]*coalescedItem),
+	}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+// Handle implements Handler
+func (b *CoalescingBuffer) Handle(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch e.Kind {
+	case Added, Updated, Deleted:
+		key := coalescingKey{collection: e.SourceName(), name: e.Resource.Metadata.FullName}
+		if p, ok := b.pending[key]; ok {
+			b.merge(key, p, e)
+			return
+		}
+		item := &coalescedItem{e: e}
+		b.items = append(b.items, item)
+		b.pending[key] = item
+
+	default:
+		// barrier: start a new segment that events can't be merged into from before.
+		b.items = append(b.items, &coalescedItem{e: e})
+		b.pending = make(map[coalescingKey]*coalescedItem)
+	}
+
+	b.cond.Broadcast()
+}
+
+// must be called with lock held
+func (b *CoalescingBuffer) merge(key coalescingKey, p *coalescedItem, e Event) {
+	switch {
+	case p.e.Kind == Added && e.Kind == Deleted:
+		// the resource never existed as far as the handlers are concerned.
+		p.removed = true
+		delete(b.pending, key)
+		return
+
+	case p.e.Kind == Added:
+		e.Kind = Added
+
+	case p.e.Kind == Deleted && e.Kind != Deleted:
+		e.Kind = Updated
+
+	case p.e.Kind == Updated && e.Kind == Added:
+		e.Kind = Updated
+	}
+
+	scope.Processing.Debugf("CoalescingBuffer.Handle: merged %v into pending %v", e, p.e)
+	p.e = e
+}
+
+// Dispatch implements Source
+func (b *CoalescingBuffer) Dispatch(handler Handler) {
+	b.handler = CombineHandlers(b.handler, handler)
+}
+
+// Clear the buffer contents.
+func (b *CoalescingBuffer) Clear() {
+	b.mu.Lock()
+	b.items = nil
+	b.pending = make(map[coalescingKey]*coalescedItem)
+	b.mu.Unlock()
+}
+
+// Size returns the number of pending events.
+func (b *CoalescingBuffer) Size() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	n := 0
+	for _, item := range b.items {
+		if !item.removed {
+			n++
+		}
+	}
+	return n
+}
+
+// Stop processing
+func (b *CoalescingBuffer) Stop() {
+	b.mu.Lock()
+	b.processing = false
+	b.cond.Broadcast()
+	b.mu.Unlock()
+}
+
+// must be called with lock held
+func (b *CoalescingBuffer) pop() (Event, bool) {
+	for len(b.items) > 0 {
+		item := b.items[0]
+		b.items[0] = nil
+		b.items = b.items[1:]
+
+		if item.removed {
+			continue
+		}
+
+		if item.e.Resource != nil {
+			key := coalescingKey{collection: item.e.SourceName(), name: item.e.Resource.Metadata.FullName}
+			if b.pending[key] == item {
+				delete(b.pending, key)
+			}
+		}
+		return item.e, true
+	}
+	return Event{}, false
+}
+
+// Process events in the buffer. This method will not return until the CoalescingBuffer is stopped.
+func (b *CoalescingBuffer) Process() {
+	b.mu.Lock()
+	if b.processing {
+		b.mu.Unlock()
+		return
+	}
+	b.processing = true
+
+	for {
+		// lock must be held when entering the for loop (whether from beginning, or through loop continuation).
+		if !b.processing {
+			scope.Processing.Debug(">>> CoalescingBuffer.Process: exiting")
+			b.mu.Unlock()
+			return
+		}
+
+		e, ok := b.pop()
+		if !ok {
+			scope.Processing.Debug("CoalescingBuffer.Process: no more items to process, waiting")
+			b.cond.Wait()
+			continue
+		}
+
+		if b.handler != nil {
+			b.mu.Unlock()
+			b.handler.Handle(e)
+			b.mu.Lock()
+		}
+	}
+}
@@ -0,0 +1,245 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package event
+
+import (
+	"regexp"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/labels"
+
+	"istio.io/libistio/pkg/config/resource"
+	"istio.io/libistio/pkg/config/schema/collection"
+)
+
+// RevisionLabel is the label that associates a resource with a control plane revision.
+const RevisionLabel = "istio.io/rev"
+
+// Predicate decides whether a resource in the given collection is in scope for a Filter.
+type Predicate func(c collection.Name, r *resource.Instance) bool
+
+// Filter is a Handler decorator that only passes through events for resources that match its predicate. Filter
+// keeps track of the resources it has passed through, so that the downstream handler sees a consistent view:
+//
+// - An Updated event for a resource that moves out of scope is delivered as Deleted.
+// - An Updated event for a resource that moves into scope is delivered as Added.
+// - A Deleted event is delivered only if the resource was previously passed through.
+// - FullSync events are always passed through. Reset events are passed through, and clear the tracked state.
+//
+// Filters can be chained by wrapping one in another, or by combining predicates with And, Or and Not.
+type Filter struct {
+	mu        sync.Mutex
+	predicate Predicate
+	handler   Handler
+	inScope   map[collection.Name]map[resource.FullName]struct{}
+}
+
+var _ Handler = &Filter{}
+
+// NewFilter returns a new Filter that passes events that match the predicate to the given handler.
+func NewFilter(h Handler, p Predicate) *Filter {
+	return &Filter{
+		predicate: p,
+		handler:   h,
+		inScope:   make(map[collection.Name]map[resource.FullName]struct{}),
+	}
+}
+
+// Handle implements Handler
+func (f *Filter) Handle(e Event) {
+	f.mu.Lock()
+	e, ok := f.translate(e)
+	f.mu.Unlock()
+
+	if ok {
+		f.handler.Handle(e)
+	}
+}
+
+func (f *Filter) translate(e Event) (Event, bool) {
+	switch e.Kind {
+	case Added, Updated, Deleted:
+	case Reset:
+		f.inScope = make(map[collection.Name]map[resource.FullName]struct{})
+		return e, true
+	default:
+		return e, true
+	}
+
+	c := e.SourceName()
+	name := e.Resource.Metadata.FullName
+	names := f.inScope[c]
+	_, wasInScope := names[name]
+
+	if e.Kind == Deleted {
+		// The labels and annotations of a deleted resource may be stale, so rely on what was passed through.
+		if !wasInScope {
+			return e, false
+		}
+		delete(names, name)
+		return e, true
+	}
+
+	if !f.predicate(c, e.Resource) {
+		if !wasInScope {
+			return e, false
+		}
+		delete(names, name)
+		e.Kind = Deleted
+		return e, true
+	}
+
+	if names == nil {
+		names = make(map[resource.FullName]struct{})
+		f.inScope[c] = names
+	}
+	names[name] = struct{}{}
+
+	switch {
+	case !wasInScope:
+		e.Kind = Added
+	case e.Kind == Added:
+		e.Kind = Updated
+	}
+	return e, true
+}
+
+// And returns a Predicate that matches if all the given predicates match.
+func And(predicates ...Predicate) Predicate {
+	return func(c collection.Name, r *resource.Instance) bool {
+		for _, p := range predicates {
+			if !p(c, r) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Or returns a Predicate that matches if any of the given predicates match.
+func Or(predicates ...Predicate) Predicate {
+	return func(c collection.Name, r *resource.Instance) bool {
+		for _, p := range predicates {
+			if p(c, r) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not returns a Predicate that matches if the given predicate does not.
+func Not(p Predicate) Predicate {
+	return func(c collection.Name, r *resource.Instance) bool {
+		return !p(c, r)
+	}
+}
+
+// ForCollections returns a Predicate that applies p to resources in the given collections only. Resources in
+// other collections always match.
+func ForCollections(p Predicate, names ...collection.Name) Predicate {
+	set := make(map[collection.Name]struct{}, len(names))
+	for _, n := range names {
+		set[n] = struct{}{}
+	}
+	return func(c collection.Name, r *resource.Instance) bool {
+		if _, ok := set[c]; !ok {
+			return true
+		}
+		return p(c, r)
+	}
+}
+
+// InNamespaces returns a Predicate that matches resources in any of the given namespaces. Cluster-scoped
+// resources always match.
+func InNamespaces(namespaces ...resource.Namespace) Predicate {
+	set := make(map[resource.Namespace]struct{}, len(namespaces))
+	for _, ns := range namespaces {
+		set[ns] = struct{}{}
+	}
+	return func(_ collection.Name, r *resource.Instance) bool {
+		ns := r.Metadata.FullName.Namespace
+		if ns == "" {
+			return true
+		}
+		_, ok := set[ns]
+		return ok
+	}
+}
+
+// NotInNamespaces returns a Predicate that matches resources that are not in any of the given namespaces.
+// Cluster-scoped resources always match.
+func NotInNamespaces(namespaces ...resource.Namespace) Predicate {
+	in := InNamespaces(namespaces...)
+	return func(c collection.Name, r *resource.Instance) bool {
+		return r.Metadata.FullName.Namespace == "" || !in(c, r)
+	}
+}
+
+// MatchLabels returns a Predicate that matches resources whose labels match the given selector.
+func MatchLabels(selector labels.Selector) Predicate {
+	return func(_ collection.Name, r *resource.Instance) bool {
+		return selector.Matches(labels.Set(r.Metadata.Labels))
+	}
+}
+
+// MatchAnnotation returns a Predicate that matches resources that have the given annotation. If values are
+// specified, the annotation must also have one of the values.
+func MatchAnnotation(key string, values ...string) Predicate {
+	return func(_ collection.Name, r *resource.Instance) bool {
+		v, ok := r.Metadata.Annotations[key]
+		if !ok {
+			return false
+		}
+		if len(values) == 0 {
+			return true
+		}
+		for _, value := range values {
+			if v == value {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// MatchName returns a Predicate that matches resources whose full name matches the given expression.
+func MatchName(re *regexp.Regexp) Predicate {
+	return func(_ collection.Name, r *resource.Instance) bool {
+		return re.MatchString(r.Metadata.FullName.String())
+	}
+}
+
+// MatchRevision returns a Predicate that matches resources labeled with the given revision, or with one of its
+// aliases (e.g. revision tags). Resources without a revision label match if matchUnlabeled is true. An empty revision
+// matches all resources.
+func MatchRevision(revision string, matchUnlabeled bool, aliases ...string) Predicate {
+	revisions := map[string]struct{}{revision: {}}
+	for _, a := range aliases {
+		revisions[a] = struct{}{}
+	}
+
+	return func(_ collection.Name, r *resource.Instance) bool {
+		if revision == "" {
+			return true
+		}
+		rev := r.Metadata.Labels[RevisionLabel]
+		if rev == "" {
+			return matchUnlabeled
+		}
+		_, ok := revisions[rev]
+		return ok
+	}
+}
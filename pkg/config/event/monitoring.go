@@ -0,0 +1,53 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package event
+
+import (
+	"istio.io/pkg/monitoring"
+)
+
+var (
+	bufferTag = monitoring.MustCreateLabel("buffer")
+	policyTag = monitoring.MustCreateLabel("policy")
+
+	// bufferDepth is a measure of the number of events queued in a buffer.
+	bufferDepth = monitoring.NewGauge(
+		"galley_event_buffer_depth",
+		"The number of events currently queued in an event buffer.",
+		monitoring.WithLabels(bufferTag),
+	)
+
+	// bufferHighWaterMark is a measure of the largest number of events ever queued in a buffer.
+	bufferHighWaterMark = monitoring.NewGauge(
+		"galley_event_buffer_high_water_mark",
+		"The largest number of events queued in an event buffer.",
+		monitoring.WithLabels(bufferTag),
+	)
+
+	// bufferOverflowsTotal is a measure of the number of times a bounded buffer overflowed.
+	bufferOverflowsTotal = monitoring.NewSum(
+		"galley_event_buffer_overflows_total",
+		"The number of times an event was added to a full bounded event buffer.",
+		monitoring.WithLabels(bufferTag, policyTag),
+	)
+)
+
+func init() {
+	monitoring.MustRegister(
+		bufferDepth,
+		bufferHighWaterMark,
+		bufferOverflowsTotal,
+	)
+}
@@ -47,6 +47,26 @@ func (q *queue) pop() (Event, bool) {
 	return q.items[idx], true
 }
 
+// removeFirst removes the first (i.e. oldest) item that matches the predicate.
+func (q *queue) removeFirst(p func(e Event) bool) (Event, bool) {
+	n := q.size()
+	for i := 0; i < n; i++ {
+		idx := wrap(q.head+i, len(q.items))
+		e := q.items[idx]
+		if !p(e) {
+			continue
+		}
+
+		// shift the items that follow to close the gap.
+		for j := i; j < n-1; j++ {
+			q.items[wrap(q.head+j, len(q.items))] = q.items[wrap(q.head+j+1, len(q.items))]
+		}
+		q.end = wrap(q.end-1+len(q.items), len(q.items))
+		return e, true
+	}
+	return Event{}, false
+}
+
 func (q *queue) clear() {
 	q.items = nil
 	q.head = 0
@@ -0,0 +1,142 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package event
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"istio.io/libistio/galley/pkg/config/scope"
+	"istio.io/libistio/pkg/config/schema/collection"
+)
+
+// Readiness is a Handler that tracks whether each of a set of collections has received its FullSync event. It is
+// typically registered with a (composite) Source, to find out when the Source has published its initial state:
+//
+// - Disabled collections are not waited for. Sources may still send FullSync events for them, which are ignored.
+// - FullSync events may arrive before any other events, as they are sent immediately for collections that are
+// not available (e.g. a missing CRD).
+// - A Reset event marks all collections as pending again.
+type Readiness struct {
+	mu       sync.Mutex
+	expected map[collection.Name]struct{}
+	synced   map[collection.Name]struct{}
+
+	// closed and replaced whenever the sync state changes.
+	notify chan struct{}
+}
+
+var _ Handler = &Readiness{}
+
+// NewReadiness returns a new Readiness that tracks the non-disabled collections in the given schemas.
+func NewReadiness(schemas collection.Schemas) *Readiness {
+	r := &Readiness{
+		expected: make(map[collection.Name]struct{}),
+		synced:   make(map[collection.Name]struct{}),
+		notify:   make(chan struct{}),
+	}
+	for _, s := range schemas.All() {
+		if !s.IsDisabled() {
+			r.expected[s.Name()] = struct{}{}
+		}
+	}
+	return r
+}
+
+// Handle implements Handler
+func (r *Readiness) Handle(e Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch e.Kind {
+	case FullSync:
+		name := e.SourceName()
+		if _, ok := r.expected[name]; !ok {
+			scope.Processing.Debugf("Readiness: ignoring FullSync for untracked collection: %v", name)
+			return
+		}
+		if _, ok := r.synced[name]; ok {
+			return
+		}
+		r.synced[name] = struct{}{}
+		if len(r.synced) == len(r.expected) {
+			scope.Processing.Infof("Readiness: all %d collections are synced", len(r.expected))
+		}
+
+	case Reset:
+		if len(r.synced) == 0 {
+			return
+		}
+		r.synced = make(map[collection.Name]struct{})
+
+	default:
+		return
+	}
+
+	close(r.notify)
+	r.notify = make(chan struct{})
+}
+
+// Synced returns true if all tracked collections have received their FullSync.
+func (r *Readiness) Synced() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.synced) == len(r.expected)
+}
+
+// Pending returns the names of the collections that haven't received their FullSync yet, in sorted order.
+func (r *Readiness) Pending() collection.Names {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.pending()
+}
+
+// must be called with lock held
+func (r *Readiness) pending() collection.Names {
+	var names collection.Names
+	for name := range r.expected {
+		if _, ok := r.synced[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// WaitForSync blocks until all tracked collections have received their FullSync, or the context is done. A timeout
+// can be set with context.WithTimeout. If the context is done first, the returned error lists the pending collections.
+func (r *Readiness) WaitForSync(ctx context.Context) error {
+	for {
+		r.mu.Lock()
+		if len(r.synced) == len(r.expected) {
+			r.mu.Unlock()
+			return nil
+		}
+		notify := r.notify
+		r.mu.Unlock()
+
+		select {
+		case <-notify:
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for collections to sync: %v: %v", ctx.Err(), r.Pending())
+		}
+	}
+}
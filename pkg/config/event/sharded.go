@@ -0,0 +1,231 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package event
+
+import (
+	"hash/fnv"
+	"sync"
+
+	"istio.io/libistio/galley/pkg/config/scope"
+)
+
+// ShardedDispatcher is a Processor that dispatches events to its handlers in parallel. Events are assigned to one of
+// a fixed number of shards by hashing their collection and resource name, and each shard is processed by its own
+// worker goroutine:
+//
+// - Events for the same resource are always handled in the order they were received.
+// - Events for different resources may be handled concurrently. Handlers must therefore be safe for concurrent use.
+// - FullSync and Reset events are barriers. They are handled only after all the events received before them have
+// been handled by all shards, and before any events received after them.
+//
+// Events are only accepted between Start and Stop; at other times they are discarded.
+type ShardedDispatcher struct {
+	// serializes calls to Handle, so that barriers are ordered with respect to other events.
+	handleMu sync.Mutex
+
+	mu      sync.Mutex
+	started bool
+	shards  []*shard
+	workers sync.WaitGroup
+	handler Handler
+}
+
+var _ Processor = &ShardedDispatcher{}
+var _ Dispatcher = &ShardedDispatcher{}
+
+type shard struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	items   []shardItem
+	stopped bool
+}
+
+type shardItem struct {
+	e Event
+
+	// if not nil, the item is a barrier marker, and the group is notified once the shard reaches it.
+	barrier *sync.WaitGroup
+}
+
+// NewShardedDispatcher returns a new ShardedDispatcher with the given number of shards. At least one shard is always
+// used.
+func NewShardedDispatcher(shards int) *ShardedDispatcher {
+	if shards < 1 {
+		shards = 1
+	}
+
+	d := &ShardedDispatcher{
+		shards: make([]*shard, shards),
+	}
+	for i := range d.shards {
+		s := &shard{stopped: true}
+		s.cond = sync.NewCond(&s.mu)
+		d.shards[i] = s
+	}
+	return d
+}
+
+// Dispatch implements Dispatcher
+func (d *ShardedDispatcher) Dispatch(handler Handler) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.handler = CombineHandlers(d.handler, handler)
+}
+
+// Start implements Processor
+func (d *ShardedDispatcher) Start() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.started {
+		return
+	}
+	d.started = true
+
+	h := d.handler
+	if h == nil {
+		h = SentinelHandler()
+	}
+
+	scope.Processing.Debugf("ShardedDispatcher.Start: starting %d workers", len(d.shards))
+	for _, s := range d.shards {
+		s.mu.Lock()
+		s.stopped = false
+		s.mu.Unlock()
+
+		d.workers.Add(1)
+		go d.run(s, h)
+	}
+}
+
+// Stop implements Processor. Events that have not been handled yet are discarded.
+func (d *ShardedDispatcher) Stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.started {
+		return
+	}
+	d.started = false
+
+	for _, s := range d.shards {
+		s.mu.Lock()
+		s.stopped = true
+		s.clear()
+		s.cond.Broadcast()
+		s.mu.Unlock()
+	}
+	d.workers.Wait()
+	scope.Processing.Debug("ShardedDispatcher.Stop: all workers exited")
+}
+
+// Handle implements Handler
+func (d *ShardedDispatcher) Handle(e Event) {
+	d.handleMu.Lock()
+	defer d.handleMu.Unlock()
+
+	switch e.Kind {
+	case Added, Updated, Deleted:
+		d.shards[d.shardFor(e)].enqueue(shardItem{e: e})
+		return
+	}
+
+	// Barrier: wait for all shards to drain the events that were received before this one.
+	var barrier sync.WaitGroup
+	barrier.Add(len(d.shards))
+	for _, s := range d.shards {
+		s.enqueue(shardItem{barrier: &barrier})
+	}
+	barrier.Wait()
+
+	d.mu.Lock()
+	started, h := d.started, d.handler
+	d.mu.Unlock()
+
+	if !started || h == nil {
+		scope.Processing.Debugf("ShardedDispatcher.Handle: not started, discarding event: %v", e)
+		return
+	}
+	h.Handle(e)
+}
+
+func (d *ShardedDispatcher) shardFor(e Event) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(e.SourceName().String()))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(e.Resource.Metadata.FullName.String()))
+	return int(h.Sum32() % uint32(len(d.shards)))
+}
+
+func (d *ShardedDispatcher) run(s *shard, h Handler) {
+	defer d.workers.Done()
+
+	for {
+		item, ok := s.dequeue()
+		if !ok {
+			return
+		}
+
+		if item.barrier != nil {
+			item.barrier.Done()
+			continue
+		}
+		h.Handle(item.e)
+	}
+}
+
+func (s *shard) enqueue(item shardItem) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopped {
+		if item.barrier != nil {
+			item.barrier.Done()
+		}
+		return
+	}
+
+	s.items = append(s.items, item)
+	s.cond.Signal()
+}
+
+// dequeue blocks until an item is available, or the shard is stopped.
+func (s *shard) dequeue() (shardItem, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for len(s.items) == 0 && !s.stopped {
+		s.cond.Wait()
+	}
+	if s.stopped {
+		return shardItem{}, false
+	}
+
+	item := s.items[0]
+	s.items[0] = shardItem{}
+	s.items = s.items[1:]
+	return item, true
+}
+
+// must be called with lock held
+func (s *shard) clear() {
+	for _, item := range s.items {
+		if item.barrier != nil {
+			item.barrier.Done()
+		}
+	}
+	s.items = nil
+}
@@ -0,0 +1,297 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Queue is the set of operations shared by the scheduled queues used by the MCP
+// source/server implementations to manage per-type watch state.
+type Queue interface {
+	Empty() bool
+	Full() bool
+	Enqueue(key string, val interface{}) bool
+	Dequeue() (string, interface{}, bool)
+	Ready() <-chan struct{}
+	Done() <-chan struct{}
+	Close()
+	Dump() string
+}
+
+var _ Queue = &UniqueQueue{}
+var _ Queue = &PriorityUniqueQueue{}
+
+// PriorityUniqueQueue is a variant of UniqueQueue that dequeues items by priority
+// instead of strictly by the order in which they were first enqueued. It has the
+// following properties in addition to those of UniqueQueue:
+//
+// - Each key may be assigned a priority. Items with a higher priority are dequeued
+//   before items with a lower priority. Keys without an explicit priority default
+//   to zero. Items with equal priority are dequeued in the order they were first
+//   enqueued.
+//
+// - Each key may depend on other keys. An item is not dequeued while an item it
+//   depends on is still in the queue, regardless of priority. Dependencies on keys
+//   that are not queued have no effect.
+//
+// The maximum queue depth is expected to be small (i.e. the number of collections),
+// so selecting the next item is a linear scan over the queued items.
+type PriorityUniqueQueue struct {
+	mu             sync.Mutex
+	doneChanClosed bool
+	doneChan       chan struct{}
+	readyChan      chan struct{}
+
+	queuedSet  map[string]*priorityEntry
+	maxDepth   int
+	nextSeq    uint64
+	priorities map[string]int
+	dependsOn  map[string][]string
+}
+
+type priorityEntry struct {
+	key string
+	val interface{}
+	seq uint64
+}
+
+// NewPriorityUniqueScheduledQueue creates a new priority-aware unique queue specialized for
+// MCP source/server implementations. The priorities and dependsOn maps are keyed by queue key.
+// An error is returned if the dependencies contain a cycle.
+func NewPriorityUniqueScheduledQueue(maxDepth int, priorities map[string]int,
+	dependsOn map[string][]string) (*PriorityUniqueQueue, error) {
+	if err := checkDependencyCycles(dependsOn); err != nil {
+		return nil, err
+	}
+
+	q := &PriorityUniqueQueue{
+		queuedSet:  make(map[string]*priorityEntry, maxDepth),
+		maxDepth:   maxDepth,
+		priorities: make(map[string]int, len(priorities)),
+		dependsOn:  make(map[string][]string, len(dependsOn)),
+		readyChan:  make(chan struct{}, maxDepth),
+		doneChan:   make(chan struct{}),
+	}
+	for k, v := range priorities {
+		q.priorities[k] = v
+	}
+	for k, v := range dependsOn {
+		q.dependsOn[k] = append([]string(nil), v...)
+	}
+	return q, nil
+}
+
+// checkDependencyCycles returns an error if following the dependencies from any key
+// leads back to the same key.
+func checkDependencyCycles(dependsOn map[string][]string) error {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int, len(dependsOn))
+
+	var visit func(key string, path []string) error
+	visit = func(key string, path []string) error {
+		switch state[key] {
+		case visiting:
+			return fmt.Errorf("dependency cycle detected: %v", append(path, key))
+		case visited:
+			return nil
+		}
+		state[key] = visiting
+		for _, dep := range dependsOn[key] {
+			if err := visit(dep, append(path, key)); err != nil {
+				return err
+			}
+		}
+		state[key] = visited
+		return nil
+	}
+
+	// iterate in a stable order so that the reported cycle is deterministic.
+	keys := make([]string, 0, len(dependsOn))
+	for k := range dependsOn {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := visit(k, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Empty returns true if the queue is empty
+func (q *PriorityUniqueQueue) Empty() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.queuedSet) == 0
+}
+
+// Full returns true if the queue is full
+func (q *PriorityUniqueQueue) Full() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.queuedSet) >= q.maxDepth
+}
+
+// Enqueue an item in the queue. Items with the same key may be safely enqueued multiple
+// times. Enqueueing an item with a key that has already queued only updates its value.
+//
+// Returns true if the item exists in the queue upon return. Otherwise,
+// returns false if the item could not be queued.
+func (q *PriorityUniqueQueue) Enqueue(key string, val interface{}) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.doneChanClosed {
+		return false
+	}
+
+	if entry, ok := q.queuedSet[key]; ok {
+		entry.val = val
+		return true
+	}
+
+	if len(q.queuedSet) >= q.maxDepth {
+		return false
+	}
+
+	q.queuedSet[key] = &priorityEntry{
+		key: key,
+		val: val,
+		seq: q.nextSeq,
+	}
+	q.nextSeq++
+
+	select {
+	case q.readyChan <- struct{}{}:
+	default:
+		scope.Warnf("priority queue could not be scheduled (len=%v depth=%v)",
+			len(q.queuedSet), q.maxDepth)
+	}
+
+	return true
+}
+
+// must be called with lock held
+func (q *PriorityUniqueQueue) blocked(key string) bool {
+	for _, dep := range q.dependsOn[key] {
+		if _, ok := q.queuedSet[dep]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// must be called with lock held
+func (q *PriorityUniqueQueue) before(a, b *priorityEntry) bool {
+	pa, pb := q.priorities[a.key], q.priorities[b.key]
+	if pa != pb {
+		return pa > pb
+	}
+	return a.seq < b.seq
+}
+
+// Dequeue removes the item with the highest priority whose dependencies are not queued.
+// This should only be called once for each time Ready() indicates a new item is ready
+// to be dequeued.
+func (q *PriorityUniqueQueue) Dequeue() (string, interface{}, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var next *priorityEntry
+	for _, entry := range q.queuedSet {
+		if q.blocked(entry.key) {
+			continue
+		}
+		if next == nil || q.before(entry, next) {
+			next = entry
+		}
+	}
+
+	if next == nil {
+		return "", nil, false
+	}
+
+	delete(q.queuedSet, next.key)
+	return next.key, next.val, true
+}
+
+func (q *PriorityUniqueQueue) Ready() <-chan struct{} {
+	return q.readyChan
+}
+
+func (q *PriorityUniqueQueue) Done() <-chan struct{} {
+	return q.doneChan
+}
+
+func (q *PriorityUniqueQueue) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if !q.doneChanClosed {
+		q.doneChanClosed = true
+		close(q.doneChan)
+	}
+}
+
+type priorityDump struct {
+	Closed     bool                   `json:"closed"`
+	QueuedSet  map[string]interface{} `json:"queued_set"`
+	Queue      []string               `json:"queue"`
+	Priorities map[string]int         `json:"priorities"`
+	DependsOn  map[string][]string    `json:"depends_on"`
+	MaxDepth   int                    `json:"max_depth"`
+}
+
+// Dump returns a JSON formatted dump of the internal queue state. The queued keys are
+// listed by priority without accounting for dependencies. This is intended for debug
+// purposes only.
+func (q *PriorityUniqueQueue) Dump() string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	d := &priorityDump{
+		Closed:     q.doneChanClosed,
+		MaxDepth:   q.maxDepth,
+		Priorities: q.priorities,
+		DependsOn:  q.dependsOn,
+		Queue:      make([]string, 0, len(q.queuedSet)),
+		QueuedSet:  make(map[string]interface{}, len(q.queuedSet)),
+	}
+
+	entries := make([]*priorityEntry, 0, len(q.queuedSet))
+	for _, entry := range q.queuedSet {
+		entries = append(entries, entry)
+		d.QueuedSet[entry.key] = entry.val
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return q.before(entries[i], entries[j])
+	})
+	for _, entry := range entries {
+		d.Queue = append(d.Queue, entry.key)
+	}
+
+	out, err := jsonMarshalDumpHook(d)
+	if err != nil {
+		return ""
+	}
+	return string(out)
+}
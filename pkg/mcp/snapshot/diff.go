@@ -0,0 +1,267 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snapshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/types"
+
+	mcp "istio.io/api/mcp/v1alpha1"
+
+	"istio.io/libistio/pkg/util/gogoprotomarshal"
+)
+
+// DiffOptions configures the comparison of snapshots.
+type DiffOptions struct {
+	// FieldDiff enables computing field-level differences for updated resources.
+	FieldDiff bool
+}
+
+// CollectionDiff is the difference between two snapshots for a single collection.
+type CollectionDiff struct {
+	Collection string
+
+	// Versions of the collection in the compared snapshots.
+	PrevVersion string
+	Version     string
+
+	// Resources that only exist in the newer snapshot, sorted by name.
+	Added []*mcp.Resource
+	// Resources that exist in both snapshots and differ, sorted by name.
+	Updated []ResourceUpdate
+	// Resources that only exist in the older snapshot, sorted by name.
+	Removed []*mcp.Resource
+}
+
+// Empty returns true if there are no resource-level differences in the collection.
+func (d *CollectionDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Updated) == 0 && len(d.Removed) == 0
+}
+
+// ResourceUpdate describes a resource that exists in both compared snapshots with different contents.
+type ResourceUpdate struct {
+	Name string
+
+	// Resource versions in the compared snapshots.
+	PrevVersion string
+	Version     string
+
+	Prev    *mcp.Resource
+	Current *mcp.Resource
+
+	// Field-level differences, sorted by path. Only populated if DiffOptions.FieldDiff is set.
+	Fields []FieldChange
+}
+
+// FieldChange is a single field-level difference between two versions of a resource. Paths are
+// dot separated, based on the canonical JSON encoding of the resource, and are rooted at either
+// "metadata" or "body". A nil Prev or Current indicates the field is absent in that version.
+type FieldChange struct {
+	Path    string
+	Prev    interface{}
+	Current interface{}
+}
+
+// Diff compares two snapshots and returns the differences per collection, sorted by collection
+// name. Collections without any differences are omitted, unless their versions differ. Either
+// snapshot may be nil, in which case it is treated as empty.
+func Diff(prev, current Snapshot, options DiffOptions) []CollectionDiff {
+	collections := make(map[string]struct{})
+	if prev != nil {
+		for _, c := range prev.Collections() {
+			collections[c] = struct{}{}
+		}
+	}
+	if current != nil {
+		for _, c := range current.Collections() {
+			collections[c] = struct{}{}
+		}
+	}
+
+	names := make([]string, 0, len(collections))
+	for c := range collections {
+		names = append(names, c)
+	}
+	sort.Strings(names)
+
+	var result []CollectionDiff
+	for _, collection := range names {
+		d := diffCollection(collection, prev, current, options)
+		if d.Empty() && d.PrevVersion == d.Version {
+			continue
+		}
+		result = append(result, d)
+	}
+	return result
+}
+
+func resourcesOf(s Snapshot, collection string) (string, map[string]*mcp.Resource) {
+	if s == nil {
+		return "", nil
+	}
+	resources := s.Resources(collection)
+	byName := make(map[string]*mcp.Resource, len(resources))
+	for _, r := range resources {
+		byName[r.Metadata.Name] = r
+	}
+	return s.Version(collection), byName
+}
+
+func diffCollection(collection string, prev, current Snapshot, options DiffOptions) CollectionDiff {
+	prevVersion, prevResources := resourcesOf(prev, collection)
+	version, resources := resourcesOf(current, collection)
+
+	d := CollectionDiff{
+		Collection:  collection,
+		PrevVersion: prevVersion,
+		Version:     version,
+	}
+
+	for name, r := range resources {
+		p, ok := prevResources[name]
+		if !ok {
+			d.Added = append(d.Added, r)
+			continue
+		}
+
+		if p.Metadata.Version == r.Metadata.Version && proto.Equal(p, r) {
+			continue
+		}
+
+		u := ResourceUpdate{
+			Name:        name,
+			PrevVersion: p.Metadata.Version,
+			Version:     r.Metadata.Version,
+			Prev:        p,
+			Current:     r,
+		}
+		if options.FieldDiff {
+			u.Fields = diffFields(p, r)
+		}
+		d.Updated = append(d.Updated, u)
+	}
+
+	for name, p := range prevResources {
+		if _, ok := resources[name]; !ok {
+			d.Removed = append(d.Removed, p)
+		}
+	}
+
+	sort.Slice(d.Added, func(i, j int) bool {
+		return d.Added[i].Metadata.Name < d.Added[j].Metadata.Name
+	})
+	sort.Slice(d.Updated, func(i, j int) bool {
+		return d.Updated[i].Name < d.Updated[j].Name
+	})
+	sort.Slice(d.Removed, func(i, j int) bool {
+		return d.Removed[i].Metadata.Name < d.Removed[j].Metadata.Name
+	})
+
+	return d
+}
+
+func diffFields(prev, current *mcp.Resource) []FieldChange {
+	var changes []FieldChange
+
+	diffJSON("metadata", toJSONMap(prev.Metadata), toJSONMap(current.Metadata), &changes)
+
+	prevBody, prevOK := bodyToJSON(prev.Body)
+	currentBody, currentOK := bodyToJSON(current.Body)
+	if prevOK && currentOK {
+		diffJSON("body", prevBody, currentBody, &changes)
+	} else if !proto.Equal(prev.Body, current.Body) {
+		// the body could not be decoded; report it as a single opaque change.
+		changes = append(changes, FieldChange{
+			Path:    "body",
+			Prev:    prev.Body,
+			Current: current.Body,
+		})
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes
+}
+
+func toJSONMap(m proto.Message) map[string]interface{} {
+	if m == nil || reflect.ValueOf(m).IsNil() {
+		return nil
+	}
+	result, err := gogoprotomarshal.ToJSONMap(m)
+	if err != nil {
+		scope.Debugf("Diff(): unable to convert %T to JSON: %v", m, err)
+		return nil
+	}
+	return result
+}
+
+// bodyToJSON decodes the body into its generic canonical JSON representation. Well-known types
+// such as wrappers do not necessarily encode as JSON objects.
+func bodyToJSON(body *types.Any) (interface{}, bool) {
+	if body == nil {
+		return nil, true
+	}
+	var dynamicAny types.DynamicAny
+	if err := types.UnmarshalAny(body, &dynamicAny); err != nil {
+		scope.Debugf("Diff(): unable to unmarshal body of type %q: %v", body.TypeUrl, err)
+		return nil, false
+	}
+	js, err := gogoprotomarshal.ToJSON(dynamicAny.Message)
+	if err != nil {
+		scope.Debugf("Diff(): unable to convert body of type %q to JSON: %v", body.TypeUrl, err)
+		return nil, false
+	}
+	var result interface{}
+	if err := json.Unmarshal([]byte(js), &result); err != nil {
+		return nil, false
+	}
+	return result, true
+}
+
+func diffJSON(path string, prev, current interface{}, changes *[]FieldChange) {
+	prevMap, prevIsMap := prev.(map[string]interface{})
+	currentMap, currentIsMap := current.(map[string]interface{})
+
+	if prevIsMap && currentIsMap {
+		for k, p := range prevMap {
+			diffJSON(fmt.Sprintf("%s.%s", path, k), p, currentMap[k], changes)
+		}
+		for k, c := range currentMap {
+			if _, ok := prevMap[k]; !ok {
+				diffJSON(fmt.Sprintf("%s.%s", path, k), nil, c, changes)
+			}
+		}
+		return
+	}
+
+	// a nil map and an empty map are equivalent in the canonical JSON encoding.
+	if (prevIsMap || prev == nil) && (currentIsMap || current == nil) && len(prevMap) == 0 && len(currentMap) == 0 {
+		return
+	}
+
+	if !reflect.DeepEqual(prev, current) {
+		*changes = append(*changes, FieldChange{
+			Path:    path,
+			Prev:    prev,
+			Current: current,
+		})
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snapshot
+
+import (
+	"time"
+)
+
+// GCOptions configures the garbage collection of stale status information in a Cache.
+type GCOptions struct {
+	// Interval between garbage collection passes.
+	Interval time.Duration
+
+	// TTL is how long the sync status of a peer without any open watch is retained
+	// after its most recent watch request.
+	TTL time.Duration
+}
+
+// RunGC periodically garbage collects stale status information until the stop channel is closed.
+func (c *Cache) RunGC(stop <-chan struct{}, options GCOptions) {
+	ticker := time.NewTicker(options.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			peers, groups := c.GC(options.TTL)
+			if peers > 0 || groups > 0 {
+				scope.Infof("GC(): removed %d stale peer(s) and %d empty group(s)", peers, groups)
+			}
+		}
+	}
+}
+
+// GC removes the sync status of peers that have no open watch and whose most recent watch
+// request is older than the given ttl. Groups that are left without any peers or open watches
+// are removed as well. Snapshots are not affected. It returns the number of removed peers and groups.
+func (c *Cache) GC(ttl time.Duration) (removedPeers int, removedGroups int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	trackedPeers := 0
+
+	for group, info := range c.status {
+		info.mu.Lock()
+
+		active := make(map[PeerKey]bool, len(info.watches))
+		for _, w := range info.watches {
+			active[w.peer] = true
+		}
+
+		for peer, lastSeen := range info.lastSeen {
+			if active[peer] || now.Sub(lastSeen) < ttl {
+				continue
+			}
+
+			scope.Debugf("GC(): removing stale peer %v from group %q (last seen %v)", peer, group, lastSeen)
+			delete(info.lastSeen, peer)
+			for collection, synced := range info.synced {
+				delete(synced, peer)
+				if len(synced) == 0 {
+					delete(info.synced, collection)
+				}
+			}
+			removedPeers++
+		}
+
+		empty := len(info.watches) == 0 && len(info.lastSeen) == 0
+		trackedPeers += len(info.lastSeen)
+		info.mu.Unlock()
+
+		if empty {
+			scope.Debugf("GC(): removing empty group %q", group)
+			delete(c.status, group)
+			removedGroups++
+		}
+	}
+
+	statusGroups.Record(float64(len(c.status)))
+	statusPeers.Record(float64(trackedPeers))
+	if removedPeers > 0 {
+		gcPeersRemovedTotal.Record(float64(removedPeers))
+	}
+	if removedGroups > 0 {
+		gcGroupsRemovedTotal.Record(float64(removedGroups))
+	}
+
+	return removedPeers, removedGroups
+}
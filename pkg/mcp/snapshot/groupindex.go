@@ -0,0 +1,119 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snapshot
+
+import (
+	"regexp"
+	"strings"
+
+	mcp "istio.io/api/mcp/v1alpha1"
+)
+
+// DefaultGroup is the group returned by DefaultGroupIndex.
+const DefaultGroup = "default"
+
+// DefaultGroupIndex is a GroupIndexFn that assigns every sink to DefaultGroup.
+func DefaultGroupIndex(_ string, _ *mcp.SinkNode) string {
+	return DefaultGroup
+}
+
+// ConstantGroupIndex returns a GroupIndexFn that assigns every sink to the given group.
+func ConstantGroupIndex(group string) GroupIndexFn {
+	return func(_ string, _ *mcp.SinkNode) string {
+		return group
+	}
+}
+
+// GroupSelector optionally selects a group for an MCP collection and node. If the selector
+// does not apply to the collection or node, it returns false.
+type GroupSelector func(collection string, node *mcp.SinkNode) (string, bool)
+
+// FirstOf returns a GroupIndexFn that consults the selectors in order and returns the group of
+// the first one that applies. If none applies, the fallback is used.
+func FirstOf(fallback GroupIndexFn, selectors ...GroupSelector) GroupIndexFn {
+	return func(collection string, node *mcp.SinkNode) string {
+		for _, s := range selectors {
+			if group, ok := s(collection, node); ok {
+				return group
+			}
+		}
+		return fallback(collection, node)
+	}
+}
+
+// ByAnnotation returns a GroupSelector that uses the value of the given SinkNode annotation
+// as the group. It does not apply to nodes on which the annotation is missing or empty.
+func ByAnnotation(key string) GroupSelector {
+	return func(_ string, node *mcp.SinkNode) (string, bool) {
+		group := node.GetAnnotations()[key]
+		return group, group != ""
+	}
+}
+
+// ByIDPrefix returns a GroupSelector that selects the given group for nodes whose ID starts with prefix.
+func ByIDPrefix(prefix, group string) GroupSelector {
+	return func(_ string, node *mcp.SinkNode) (string, bool) {
+		if node == nil || !strings.HasPrefix(node.Id, prefix) {
+			return "", false
+		}
+		return group, true
+	}
+}
+
+// ByIDRegexp returns a GroupSelector for nodes whose ID matches the regular expression. The group is
+// expanded from template as with regexp.Regexp.Expand, so that it can refer to submatches of the ID,
+// e.g. "$1" or "${tenant}". It does not apply if the expanded group is empty.
+func ByIDRegexp(re *regexp.Regexp, template string) GroupSelector {
+	return func(_ string, node *mcp.SinkNode) (string, bool) {
+		if node == nil {
+			return "", false
+		}
+		match := re.FindStringSubmatchIndex(node.Id)
+		if match == nil {
+			return "", false
+		}
+		group := string(re.ExpandString(nil, template, node.Id, match))
+		return group, group != ""
+	}
+}
+
+// ForCollections restricts the selector to the given collections. It does not apply to any other collection.
+func ForCollections(selector GroupSelector, collections ...string) GroupSelector {
+	set := make(map[string]struct{}, len(collections))
+	for _, c := range collections {
+		set[c] = struct{}{}
+	}
+	return func(collection string, node *mcp.SinkNode) (string, bool) {
+		if _, ok := set[collection]; !ok {
+			return "", false
+		}
+		return selector(collection, node)
+	}
+}
+
+// WithCollectionOverrides returns a GroupIndexFn that uses the override registered for a collection,
+// and the given GroupIndexFn for all other collections.
+func WithCollectionOverrides(groupIndex GroupIndexFn, overrides map[string]GroupIndexFn) GroupIndexFn {
+	o := make(map[string]GroupIndexFn, len(overrides))
+	for k, v := range overrides {
+		o[k] = v
+	}
+	return func(collection string, node *mcp.SinkNode) string {
+		if fn, ok := o[collection]; ok {
+			return fn(collection, node)
+		}
+		return groupIndex(collection, node)
+	}
+}
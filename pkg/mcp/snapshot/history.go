@@ -0,0 +1,117 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snapshot
+
+import (
+	"fmt"
+	"time"
+)
+
+// HistoryEntry is a snapshot previously published for a group.
+type HistoryEntry struct {
+	// Version of the entry. Versions are assigned in increasing order of publication
+	// within a group and are never reused.
+	Version int64
+	// Time the snapshot was published.
+	Time time.Time
+	// Reason for the publication, as provided by the publisher.
+	Reason string
+	// Snapshot that was published.
+	Snapshot Snapshot
+}
+
+// SetHistoryLimit sets the number of most recently published snapshots that are kept
+// per group. A limit of zero, the default, disables the history. Lowering the limit
+// trims the history of every group.
+func (c *Cache) SetHistoryLimit(limit int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if limit < 0 {
+		limit = 0
+	}
+	c.historyLimit = limit
+
+	for group, entries := range c.history {
+		if limit == 0 {
+			delete(c.history, group)
+			continue
+		}
+		c.history[group] = trimHistory(entries, limit)
+	}
+}
+
+// History returns the snapshots kept for the given group, oldest first.
+func (c *Cache) History(group string) []HistoryEntry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entries := c.history[group]
+	result := make([]HistoryEntry, 0, len(entries))
+	for _, e := range entries {
+		result = append(result, *e)
+	}
+	return result
+}
+
+// Rollback re-publishes the snapshot with the given history version to all watches of the group.
+// The rollback itself is recorded as a new history entry.
+func (c *Cache) Rollback(group string, version int64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var target *HistoryEntry
+	for _, e := range c.history[group] {
+		if e.Version == version {
+			target = e
+			break
+		}
+	}
+	if target == nil {
+		return fmt.Errorf("snapshot version %d not found in the history of group %q", version, group)
+	}
+
+	scope.Infof("Rollback(): re-publishing snapshot version %d of group %q (published %v, reason: %q)",
+		version, group, target.Time, target.Reason)
+
+	c.recordHistory(group, target.Snapshot, fmt.Sprintf("rollback to version %d", version))
+	c.setSnapshot(group, target.Snapshot)
+	return nil
+}
+
+// must be called with lock held
+func (c *Cache) recordHistory(group string, snapshot Snapshot, reason string) {
+	if c.historyLimit == 0 {
+		return
+	}
+
+	c.historySeq[group]++
+	entry := &HistoryEntry{
+		Version:  c.historySeq[group],
+		Time:     time.Now(),
+		Reason:   reason,
+		Snapshot: snapshot,
+	}
+	c.history[group] = trimHistory(append(c.history[group], entry), c.historyLimit)
+}
+
+func trimHistory(entries []*HistoryEntry, limit int) []*HistoryEntry {
+	if len(entries) <= limit {
+		return entries
+	}
+	trimmed := make([]*HistoryEntry, limit)
+	copy(trimmed, entries[len(entries)-limit:])
+	return trimmed
+}
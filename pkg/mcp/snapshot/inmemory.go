@@ -1,65 +1,174 @@
-//  Copyright Istio Authors
+// Copyright Istio Authors
 //
-//  Licensed under the Apache License, Version 2.0 (the "License");
-//  you may not use this file except in compliance with the License.
-//  You may obtain a copy of the License at
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
 //
-//      http://www.apache.org/licenses/LICENSE-2.0
+//     http://www.apache.org/licenses/LICENSE-2.0
 //
-//  Unless required by applicable law or agreed to in writing, software
-//  distributed under the License is distributed on an "AS IS" BASIS,
-//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
-//  See the License for the specific language governing permissions and
-//  limitations under the License.
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
 
 package snapshot
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
 	"fmt"
 	"sort"
-	"strings"
+	"sync"
 	"time"
 
 	"github.com/gogo/protobuf/proto"
 	"github.com/gogo/protobuf/types"
 
 	mcp "istio.io/api/mcp/v1alpha1"
+
+	"istio.io/libistio/pkg/mcp/sink"
 )
 
 // InMemory Snapshot implementation
 type InMemory struct {
-	resources map[string][]*mcp.Resource
+	resources map[string]*inMemoryCollection
 	versions  map[string]string
+
+	// contentVersions is retained so that builders derived from this snapshot
+	// use the same versioning scheme.
+	contentVersions bool
 }
 
 var _ Snapshot = &InMemory{}
 
+// inMemoryCollection holds the resources of a collection indexed by name. The sorted view and
+// the decoded objects are computed lazily, as the snapshot is read concurrently once built.
+type inMemoryCollection struct {
+	entries map[string]*mcp.Resource
+
+	mu      sync.Mutex
+	sorted  []*mcp.Resource
+	objects map[string]*sink.Object
+}
+
+func newInMemoryCollection(size int) *inMemoryCollection {
+	return &inMemoryCollection{
+		entries: make(map[string]*mcp.Resource, size),
+	}
+}
+
+// must only be called by the builder, before the snapshot is built.
+func (c *inMemoryCollection) set(e *mcp.Resource) {
+	c.entries[e.Metadata.Name] = e
+	c.sorted = nil
+	delete(c.objects, e.Metadata.Name)
+}
+
+// must only be called by the builder, before the snapshot is built.
+func (c *inMemoryCollection) delete(name string) {
+	delete(c.entries, name)
+	c.sorted = nil
+	delete(c.objects, name)
+}
+
+// view returns the resources of the collection, sorted by name.
+func (c *inMemoryCollection) view() []*mcp.Resource {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.sorted == nil {
+		sorted := make([]*mcp.Resource, 0, len(c.entries))
+		for _, e := range c.entries {
+			sorted = append(sorted, e)
+		}
+		sort.Slice(sorted, func(i, j int) bool {
+			return sorted[i].Metadata.Name < sorted[j].Metadata.Name
+		})
+		c.sorted = sorted
+	}
+	return c.sorted
+}
+
+// object returns the decoded resource with the given name, or nil if it doesn't exist or
+// cannot be decoded.
+func (c *inMemoryCollection) object(name string) *sink.Object {
+	e, ok := c.entries[name]
+	if !ok {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if o, ok := c.objects[name]; ok {
+		return o
+	}
+
+	var dynamicAny types.DynamicAny
+	if err := types.UnmarshalAny(e.Body, &dynamicAny); err != nil {
+		return nil
+	}
+	o := &sink.Object{
+		TypeURL:  e.Body.TypeUrl,
+		Metadata: e.Metadata,
+		Body:     dynamicAny.Message,
+	}
+	if c.objects == nil {
+		c.objects = make(map[string]*sink.Object)
+	}
+	c.objects[name] = o
+	return o
+}
+
 // InMemoryBuilder is a builder for an InMemory snapshot.
 type InMemoryBuilder struct {
 	snapshot *InMemory
 }
 
+// BuilderOption configures an InMemoryBuilder.
+type BuilderOption func(b *InMemoryBuilder)
+
+// WithContentVersions configures the builder to derive the version of each collection from the
+// names and versions of its resources when the snapshot is built. Identical contents always produce
+// identical versions, and any added, removed or re-versioned resource produces a new version.
+// Versions provided through Set or SetVersion are ignored.
+func WithContentVersions() BuilderOption {
+	return func(b *InMemoryBuilder) {
+		b.snapshot.contentVersions = true
+	}
+}
+
 // NewInMemoryBuilder creates and returns a new InMemoryBuilder.
-func NewInMemoryBuilder() *InMemoryBuilder {
+func NewInMemoryBuilder(options ...BuilderOption) *InMemoryBuilder {
 	snapshot := &InMemory{
-		resources: make(map[string][]*mcp.Resource),
+		resources: make(map[string]*inMemoryCollection),
 		versions:  make(map[string]string),
 	}
 
-	return &InMemoryBuilder{
+	b := &InMemoryBuilder{
 		snapshot: snapshot,
 	}
+	for _, o := range options {
+		o(b)
+	}
+	return b
 }
 
-// Set the values for a given collection. If Set is called after a call to Freeze, then this method panics.
+// Set the values for a given collection. Resources are indexed by name: if resources contains multiple
+// entries with the same name, only the last one is retained. If Set is called after a call to Build,
+// then this method panics.
 func (b *InMemoryBuilder) Set(collection, version string, resources []*mcp.Resource) {
-	b.snapshot.resources[collection] = resources
+	c := newInMemoryCollection(len(resources))
+	for _, e := range resources {
+		c.set(e)
+	}
+	b.snapshot.resources[collection] = c
 	b.snapshot.versions[collection] = version
 }
 
-// SetEntry sets a single entry. Note that this is a slow operation, as update requires scanning
-// through existing entries.
+// SetEntry sets a single entry.
 func (b *InMemoryBuilder) SetEntry(collection, name, version string, createTime time.Time, labels,
 	annotations map[string]string, m proto.Message) error {
 	body, err := types.MarshalAny(m)
@@ -83,42 +192,33 @@ func (b *InMemoryBuilder) SetEntry(collection, name, version string, createTime
 		Body: body,
 	}
 
-	entries := b.snapshot.resources[collection]
-
-	for i, prev := range entries {
-		if prev.Metadata.Name == e.Metadata.Name {
-			entries[i] = e
-			return nil
-		}
+	c, found := b.snapshot.resources[collection]
+	if !found {
+		c = newInMemoryCollection(1)
+		b.snapshot.resources[collection] = c
 	}
-
-	entries = append(entries, e)
-	b.snapshot.resources[collection] = entries
+	c.set(e)
 	return nil
 }
 
 // DeleteEntry deletes the named entry within the given collection.
 func (b *InMemoryBuilder) DeleteEntry(collection string, name string) {
-
-	entries, found := b.snapshot.resources[collection]
+	c, found := b.snapshot.resources[collection]
 	if !found {
 		return
 	}
 
-	for i, e := range entries {
-		if e.Metadata.Name == name {
-			if len(entries) == 1 {
-				delete(b.snapshot.resources, collection)
-				delete(b.snapshot.versions, collection)
-				return
-			}
-
-			entries = append(entries[:i], entries[i+1:]...)
-			b.snapshot.resources[collection] = entries
+	if _, found = c.entries[name]; !found {
+		return
+	}
 
-			return
-		}
+	if len(c.entries) == 1 {
+		delete(b.snapshot.resources, collection)
+		delete(b.snapshot.versions, collection)
+		return
 	}
+
+	c.delete(name)
 }
 
 // SetVersion sets the version for the given collection
@@ -130,15 +230,73 @@ func (b *InMemoryBuilder) SetVersion(collection string, version string) {
 func (b *InMemoryBuilder) Build() *InMemory {
 	sn := b.snapshot
 
+	if sn.contentVersions {
+		for collection, c := range sn.resources {
+			sn.versions[collection] = contentVersion(c.view())
+		}
+		// collections that were only versioned, through SetVersion, have no resources.
+		for collection := range sn.versions {
+			if _, ok := sn.resources[collection]; !ok {
+				sn.versions[collection] = contentVersion(nil)
+			}
+		}
+	}
+
 	// Avoid mutation after build
 	b.snapshot = nil
 
 	return sn
 }
 
-// Resources is an implementation of Snapshot.Resources
+// contentVersion synthesizes a collection version from the names and versions of its resources,
+// which must be sorted by name.
+func contentVersion(resources []*mcp.Resource) string {
+	h := sha256.New()
+	for _, e := range resources {
+		// separate fields so that adjacent names and versions cannot be confused.
+		_, _ = h.Write([]byte(e.Metadata.Name))
+		_, _ = h.Write([]byte{0})
+		_, _ = h.Write([]byte(e.Metadata.Version))
+		_, _ = h.Write([]byte{0})
+	}
+
+	return "$" + base64.RawStdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// Resources is an implementation of Snapshot.Resources. The resources are sorted by name, regardless
+// of the order in which they were set. The returned slice is shared and must not be modified.
 func (s *InMemory) Resources(collection string) []*mcp.Resource {
-	return s.resources[collection]
+	c, ok := s.resources[collection]
+	if !ok {
+		return nil
+	}
+	return c.view()
+}
+
+// Resource returns the named resource within the given collection, or nil if it doesn't exist.
+func (s *InMemory) Resource(collection, name string) *mcp.Resource {
+	if c, ok := s.resources[collection]; ok {
+		return c.entries[name]
+	}
+	return nil
+}
+
+// object returns the named, decoded resource within the given collection. Decoded resources
+// are cached in the snapshot, so a copy is returned to keep callers from modifying the cache.
+func (s *InMemory) object(collection, name string) *sink.Object {
+	c, ok := s.resources[collection]
+	if !ok {
+		return nil
+	}
+	o := c.object(name)
+	if o == nil {
+		return nil
+	}
+	return &sink.Object{
+		TypeURL:  o.TypeURL,
+		Metadata: proto.Clone(o.Metadata).(*mcp.Metadata),
+		Body:     proto.Clone(o.Body),
+	}
 }
 
 // Version is an implementation of Snapshot.Version
@@ -158,8 +316,9 @@ func (s *InMemory) Collections() []string {
 // Clone this snapshot.
 func (s *InMemory) Clone() *InMemory {
 	c := &InMemory{
-		resources: make(map[string][]*mcp.Resource),
-		versions:  make(map[string]string),
+		resources:       make(map[string]*inMemoryCollection, len(s.resources)),
+		versions:        make(map[string]string, len(s.versions)),
+		contentVersions: s.contentVersions,
 	}
 
 	for k, v := range s.versions {
@@ -167,11 +326,11 @@ func (s *InMemory) Clone() *InMemory {
 	}
 
 	for k, v := range s.resources {
-		envs := make([]*mcp.Resource, len(v))
-		for i, e := range v {
-			envs[i] = proto.Clone(e).(*mcp.Resource)
+		col := newInMemoryCollection(len(v.entries))
+		for name, e := range v.entries {
+			col.entries[name] = proto.Clone(e).(*mcp.Resource)
 		}
-		c.resources[k] = envs
+		c.resources[k] = col
 	}
 
 	return c
@@ -198,16 +357,7 @@ func (s *InMemory) String() string {
 	for i, n := range messages {
 		_, _ = fmt.Fprintf(&b, "[%d] (%s @%s)\n", i, n, s.versions[n])
 
-		envs := s.resources[n]
-
-		// Avoid mutating the original data
-		entries := make([]*mcp.Resource, len(envs))
-		copy(entries, envs)
-		sort.Slice(entries, func(i, j int) bool {
-			return strings.Compare(entries[i].Metadata.Name, entries[j].Metadata.Name) == -1
-		})
-
-		for j, entry := range entries {
+		for j, entry := range s.resources[n].view() {
 			_, _ = fmt.Fprintf(&b, "  [%d] (%s)\n", j, entry.Metadata.Name)
 		}
 	}
@@ -0,0 +1,96 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snapshot
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/types"
+)
+
+const (
+	benchCollection = "istio/networking/v1alpha3/serviceentries"
+	benchResources  = 50000
+)
+
+func benchNames(n int) []string {
+	names := make([]string, n)
+	for i := range names {
+		names[i] = fmt.Sprintf("ns-%d/name-%d", i%100, i)
+	}
+	return names
+}
+
+func benchBuilder(b *testing.B, names []string) *InMemoryBuilder {
+	builder := NewInMemoryBuilder()
+	now := time.Now()
+	for _, name := range names {
+		if err := builder.SetEntry(benchCollection, name, "v1", now, nil, nil, &types.StringValue{Value: name}); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return builder
+}
+
+func BenchmarkInMemoryBuilder_SetEntry(b *testing.B) {
+	names := benchNames(benchResources)
+	now := time.Now()
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		builder := NewInMemoryBuilder()
+		for _, name := range names {
+			if err := builder.SetEntry(benchCollection, name, "v1", now, nil, nil, &types.StringValue{Value: name}); err != nil {
+				b.Fatal(err)
+			}
+		}
+		_ = builder.Build()
+	}
+}
+
+func BenchmarkInMemoryBuilder_DeleteEntry(b *testing.B) {
+	names := benchNames(benchResources)
+	snapshot := benchBuilder(b, names).Build()
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		builder := snapshot.Builder()
+		b.StartTimer()
+
+		for _, name := range names {
+			builder.DeleteEntry(benchCollection, name)
+		}
+	}
+}
+
+func BenchmarkCache_GetResource(b *testing.B) {
+	names := benchNames(benchResources)
+	c := New(DefaultGroupIndex)
+	c.SetSnapshot(DefaultGroup, benchBuilder(b, names).Build())
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		name := names[i%len(names)]
+		if o := c.GetResource(DefaultGroup, benchCollection, name); o == nil {
+			b.Fatalf("resource %q not found", name)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snapshot
+
+import (
+	"istio.io/pkg/monitoring"
+)
+
+var (
+	// statusGroups is a measure of the number of groups with status information.
+	statusGroups = monitoring.NewGauge(
+		"istio_mcp_snapshot_status_groups",
+		"The number of snapshot groups with watch status information.",
+	)
+
+	// statusPeers is a measure of the number of peers tracked across all groups.
+	statusPeers = monitoring.NewGauge(
+		"istio_mcp_snapshot_status_peers",
+		"The number of peers with sync status information across all snapshot groups.",
+	)
+
+	// gcPeersRemovedTotal is a measure of the number of stale peers removed.
+	gcPeersRemovedTotal = monitoring.NewSum(
+		"istio_mcp_snapshot_gc_peers_removed_total",
+		"The number of stale peers removed from snapshot group status.",
+	)
+
+	// gcGroupsRemovedTotal is a measure of the number of empty groups removed.
+	gcGroupsRemovedTotal = monitoring.NewSum(
+		"istio_mcp_snapshot_gc_groups_removed_total",
+		"The number of empty groups removed from snapshot status.",
+	)
+)
+
+func init() {
+	monitoring.MustRegister(
+		statusGroups,
+		statusPeers,
+		gcPeersRemovedTotal,
+		gcGroupsRemovedTotal,
+	)
+}
@@ -0,0 +1,164 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snapshot
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	protoio "github.com/gogo/protobuf/io"
+
+	mcp "istio.io/api/mcp/v1alpha1"
+)
+
+// The on-disk encoding of a snapshot is:
+//
+//	magic
+//	for each collection, sorted by name:
+//	  mcp.Resources{Collection: <name>, SystemVersionInfo: <version>}
+//	  mcp.Resource, for each resource in the collection, sorted by name
+//	  mcp.Resource{} (empty, marks the end of the collection)
+//
+// All messages are length-delimited with a varint prefix.
+var persistMagic = []byte("MCPSNAP1")
+
+// maxPersistedMessageSize is the largest single message accepted when loading a snapshot.
+const maxPersistedMessageSize = 64 * 1024 * 1024
+
+// Save writes the contents of the snapshot to w.
+func Save(w io.Writer, s Snapshot) error {
+	if _, err := w.Write(persistMagic); err != nil {
+		return err
+	}
+
+	collections := s.Collections()
+	sort.Strings(collections)
+
+	dw := protoio.NewDelimitedWriter(w)
+	for _, collection := range collections {
+		header := &mcp.Resources{
+			Collection:        collection,
+			SystemVersionInfo: s.Version(collection),
+		}
+		if err := dw.WriteMsg(header); err != nil {
+			return fmt.Errorf("error writing collection %q: %v", collection, err)
+		}
+
+		resources := make([]*mcp.Resource, len(s.Resources(collection)))
+		copy(resources, s.Resources(collection))
+		sort.Slice(resources, func(i, j int) bool {
+			return resources[i].Metadata.Name < resources[j].Metadata.Name
+		})
+
+		for _, r := range resources {
+			if err := dw.WriteMsg(r); err != nil {
+				return fmt.Errorf("error writing resource %q of collection %q: %v", r.Metadata.Name, collection, err)
+			}
+		}
+
+		if err := dw.WriteMsg(&mcp.Resource{}); err != nil {
+			return fmt.Errorf("error writing collection %q: %v", collection, err)
+		}
+	}
+
+	return nil
+}
+
+// Load reads a snapshot previously written with Save from r. The persisted collection versions are retained.
+func Load(r io.Reader) (*InMemory, error) {
+	magic := make([]byte, len(persistMagic))
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return nil, fmt.Errorf("error reading snapshot header: %v", err)
+	}
+	if !bytes.Equal(magic, persistMagic) {
+		return nil, fmt.Errorf("unrecognized snapshot encoding %q", magic)
+	}
+
+	b := NewInMemoryBuilder()
+	dr := protoio.NewDelimitedReader(r, maxPersistedMessageSize)
+	for {
+		header := &mcp.Resources{}
+		if err := dr.ReadMsg(header); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("error reading collection: %v", err)
+		}
+		if header.Collection == "" {
+			return nil, fmt.Errorf("error reading collection: missing name")
+		}
+
+		var resources []*mcp.Resource
+		for {
+			e := &mcp.Resource{}
+			if err := dr.ReadMsg(e); err != nil {
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				return nil, fmt.Errorf("error reading resource of collection %q: %v", header.Collection, err)
+			}
+			if e.Metadata == nil {
+				break
+			}
+			resources = append(resources, e)
+		}
+
+		b.Set(header.Collection, header.SystemVersionInfo, resources)
+	}
+
+	return b.Build(), nil
+}
+
+// SaveFile writes the contents of the snapshot to the file at the given path. The file is
+// replaced atomically, so that a concurrent or subsequent LoadFile never observes a partially
+// written snapshot.
+func SaveFile(path string, s Snapshot) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+
+	if err = Save(f, s); err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// LoadFile reads a snapshot previously written with SaveFile.
+func LoadFile(path string) (*InMemory, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	return Load(f)
+}
@@ -19,6 +19,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/gogo/protobuf/proto"
 	"github.com/gogo/protobuf/types"
 
 	mcp "istio.io/api/mcp/v1alpha1"
@@ -45,8 +46,11 @@ type Info struct {
 	Version string
 	// Names of the resource entries.
 	Names []string
-	// Synced of this collection, including peerAddr and synced status
+	// Synced of this collection, including peerAddr and synced status. Peers behind the same
+	// address are merged; see Peers for the status of individual sinks.
 	Synced map[string]bool
+	// Peers is the detailed sync status of this collection per sink, sorted by node ID and address.
+	Peers []PeerInfo
 }
 
 // Cache is a snapshot-based cache that maintains a single versioned
@@ -59,6 +63,11 @@ type Cache struct {
 	watchCount int64
 
 	groupIndex GroupIndexFn
+
+	// snapshot history by group, oldest first. At most historyLimit entries are kept per group.
+	history      map[string][]*HistoryEntry
+	historyLimit int
+	historySeq   map[string]int64
 }
 
 // GroupIndexFn returns a stable group index for the given MCP collection and node.
@@ -73,6 +82,8 @@ func New(groupIndex GroupIndexFn) *Cache {
 		snapshots:  make(map[string]Snapshot),
 		status:     make(map[string]*StatusInfo),
 		groupIndex: groupIndex,
+		history:    make(map[string][]*HistoryEntry),
+		historySeq: make(map[string]int64),
 	}
 }
 
@@ -81,6 +92,7 @@ var _ source.Watcher = &Cache{}
 type responseWatch struct {
 	request      *source.Request
 	pushResponse source.PushResponseFunc
+	peer         PeerKey
 }
 
 // StatusInfo records watch status information of a group.
@@ -88,8 +100,10 @@ type StatusInfo struct {
 	mu                   sync.RWMutex
 	lastWatchRequestTime time.Time // informational
 	watches              map[int64]*responseWatch
-	// the synced structure is {Collection: {peerAddress: synced|nosynced}}.
-	synced map[string]map[string]bool
+	// the synced structure is {Collection: {peer: status}}.
+	synced map[string]map[PeerKey]*PeerStatus
+	// the time the most recent watch request was received, by peer.
+	lastSeen map[PeerKey]time.Time
 }
 
 // Watches returns the number of open watches.
@@ -117,7 +131,9 @@ func (c *Cache) Watch(
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	info := c.fillStatus(group, request, peerAddr)
+	peer := newPeerKey(request.SinkNode, peerAddr)
+	info := c.fillStatus(group, request, peer)
+	status := info.synced[request.Collection][peer]
 
 	collection := request.Collection
 
@@ -138,9 +154,10 @@ func (c *Cache) Watch(
 				Request:    request,
 			}
 			pushResponse(response)
+			status.pushed(version)
 			return nil
 		}
-		info.synced[request.Collection][peerAddr] = true
+		status.Synced = request.ErrorDetail == nil
 	}
 
 	// Otherwise, open a watch if no snapshot was available or the requested version is up-to-date.
@@ -151,7 +168,7 @@ func (c *Cache) Watch(
 		watchID, collection, group, request.VersionInfo)
 
 	info.mu.Lock()
-	info.watches[watchID] = &responseWatch{request: request, pushResponse: pushResponse}
+	info.watches[watchID] = &responseWatch{request: request, pushResponse: pushResponse, peer: peer}
 	info.mu.Unlock()
 
 	cancel := func() {
@@ -166,49 +183,36 @@ func (c *Cache) Watch(
 	return cancel
 }
 
-func (c *Cache) fillStatus(group string, request *source.Request, peerAddr string) *StatusInfo {
-
+func (c *Cache) fillStatus(group string, request *source.Request, peer PeerKey) *StatusInfo {
 	info, ok := c.status[group]
 	if !ok {
 		info = &StatusInfo{
-			watches: make(map[int64]*responseWatch),
-			synced:  make(map[string]map[string]bool),
+			watches:  make(map[int64]*responseWatch),
+			synced:   make(map[string]map[PeerKey]*PeerStatus),
+			lastSeen: make(map[PeerKey]time.Time),
 		}
-		peerStatus := make(map[string]bool)
-		peerStatus[peerAddr] = false
-		info.synced[request.Collection] = peerStatus
 		c.status[group] = info
-	} else {
-		collectionExists := false
-		peerExists := false
-		for collection, synced := range info.synced {
-			if collection == request.Collection {
-				collectionExists = true
-				for addr := range synced {
-					if addr == peerAddr {
-						peerExists = true
-						break
-					}
-				}
-			}
-			if collectionExists && peerExists {
-				break
-			}
-		}
-		if !collectionExists {
-			// initiate the synced map
-			peerStatus := make(map[string]bool)
-			peerStatus[peerAddr] = false
-			info.synced[request.Collection] = peerStatus
-		}
-		if !peerExists {
-			info.synced[request.Collection][peerAddr] = false
-		}
+	}
+
+	peers, ok := info.synced[request.Collection]
+	if !ok {
+		// initiate the synced map
+		peers = make(map[PeerKey]*PeerStatus)
+		info.synced[request.Collection] = peers
+	}
+	status, ok := peers[peer]
+	if !ok {
+		status = &PeerStatus{}
+		peers[peer] = status
 	}
 
 	// update last responseWatch request time
+	now := time.Now()
+	status.requested(request, now)
+
 	info.mu.Lock()
-	info.lastWatchRequestTime = time.Now()
+	info.lastWatchRequestTime = now
+	info.lastSeen[peer] = now
 	info.mu.Unlock()
 
 	return info
@@ -216,9 +220,21 @@ func (c *Cache) fillStatus(group string, request *source.Request, peerAddr strin
 
 // SetSnapshot updates a snapshot for a group.
 func (c *Cache) SetSnapshot(group string, snapshot Snapshot) {
+	c.SetSnapshotWithReason(group, snapshot, "")
+}
+
+// SetSnapshotWithReason updates a snapshot for a group, recording the reason for the
+// publication in the snapshot history of the group.
+func (c *Cache) SetSnapshotWithReason(group string, snapshot Snapshot, reason string) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	c.recordHistory(group, snapshot, reason)
+	c.setSnapshot(group, snapshot)
+}
+
+// must be called with lock held
+func (c *Cache) setSnapshot(group string, snapshot Snapshot) {
 	// update the existing entry
 	c.snapshots[group] = snapshot
 
@@ -240,6 +256,9 @@ func (c *Cache) SetSnapshot(group string, snapshot Snapshot) {
 					Request:    watch.request,
 				}
 				watch.pushResponse(response)
+				if status, ok := info.synced[watch.request.Collection][watch.peer]; ok {
+					status.pushed(version)
+				}
 
 				// discard the responseWatch
 				delete(info.watches, id)
@@ -307,9 +326,16 @@ func (c *Cache) GetSnapshotInfo(group string) []Info {
 
 	// if the group is empty, then use the default one
 	if group == "" {
-		group = c.GetGroups()[0]
+		groups := c.GetGroups()
+		if len(groups) == 0 {
+			return nil
+		}
+		group = groups[0]
 	}
 
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
 	if snapshot, ok := c.snapshots[group]; ok {
 
 		var snapshots []Info
@@ -327,15 +353,24 @@ func (c *Cache) GetSnapshotInfo(group string) []Info {
 			sort.Strings(entrieNames)
 
 			synced := make(map[string]bool)
+			var peers []PeerInfo
 			if statusInfo, found := c.status[group]; found {
-				synced = statusInfo.synced[collection]
+				// copy, as the status may be garbage collected concurrently
+				for peer, status := range statusInfo.synced[collection] {
+					synced[peer.PeerAddr] = synced[peer.PeerAddr] || status.Synced
+					peers = append(peers, PeerInfo{PeerKey: peer, PeerStatus: *status})
+				}
 			}
+			sort.Slice(peers, func(i, j int) bool {
+				return peers[i].PeerKey.less(peers[j].PeerKey)
+			})
 
 			info := Info{
 				Collection: collection,
 				Version:    snapshot.Version(collection),
 				Names:      entrieNames,
 				Synced:     synced,
+				Peers:      peers,
 			}
 			snapshots = append(snapshots, info)
 		}
@@ -344,27 +379,39 @@ func (c *Cache) GetSnapshotInfo(group string) []Info {
 	return nil
 }
 
-// GetResource returns the mcp resource detailed information for the specified group
-func (c *Cache) GetResource(group string, collection string, resourceName string) *sink.Object {
-
-	c.mu.Lock()
-	defer c.mu.Unlock()
+// objectGetter is implemented by snapshots that support indexed lookups of decoded resources.
+type objectGetter interface {
+	object(collection, name string) *sink.Object
+}
 
+// GetResource returns the mcp resource detailed information for the specified group. The returned
+// object is a copy, and may be modified by the caller.
+func (c *Cache) GetResource(group string, collection string, resourceName string) *sink.Object {
 	// if the group or collection is empty, return empty
 	if group == "" || collection == "" {
 		return nil
 	}
 
-	if snapshot, ok := c.snapshots[group]; ok {
-		for _, resource := range snapshot.Resources(collection) {
-			if resource.Metadata.Name == resourceName {
-				var dynamicAny types.DynamicAny
-				if err := types.UnmarshalAny(resource.Body, &dynamicAny); err == nil {
-					return &sink.Object{
-						TypeURL:  resource.Body.TypeUrl,
-						Metadata: resource.Metadata,
-						Body:     dynamicAny.Message,
-					}
+	// snapshots are immutable, so only the lookup needs to be done with the lock held.
+	c.mu.RLock()
+	snapshot, ok := c.snapshots[group]
+	c.mu.RUnlock()
+	if !ok {
+		return nil
+	}
+
+	if g, ok := snapshot.(objectGetter); ok {
+		return g.object(collection, resourceName)
+	}
+
+	for _, resource := range snapshot.Resources(collection) {
+		if resource.Metadata.Name == resourceName {
+			var dynamicAny types.DynamicAny
+			if err := types.UnmarshalAny(resource.Body, &dynamicAny); err == nil {
+				return &sink.Object{
+					TypeURL:  resource.Body.TypeUrl,
+					Metadata: proto.Clone(resource.Metadata).(*mcp.Metadata),
+					Body:     dynamicAny.Message,
 				}
 			}
 		}
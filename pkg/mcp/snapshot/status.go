@@ -0,0 +1,92 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snapshot
+
+import (
+	"fmt"
+	"time"
+
+	mcp "istio.io/api/mcp/v1alpha1"
+
+	"istio.io/libistio/pkg/mcp/source"
+)
+
+// PeerKey identifies a sink by its node ID and its peer address. The node ID distinguishes sinks
+// that share an address, e.g. behind NAT.
+type PeerKey struct {
+	NodeID   string
+	PeerAddr string
+}
+
+func newPeerKey(node *mcp.SinkNode, peerAddr string) PeerKey {
+	return PeerKey{
+		NodeID:   node.GetId(),
+		PeerAddr: peerAddr,
+	}
+}
+
+// String implements Stringer.String.
+func (k PeerKey) String() string {
+	return fmt.Sprintf("%s@%s", k.NodeID, k.PeerAddr)
+}
+
+func (k PeerKey) less(o PeerKey) bool {
+	if k.NodeID != o.NodeID {
+		return k.NodeID < o.NodeID
+	}
+	return k.PeerAddr < o.PeerAddr
+}
+
+// PeerStatus is the sync status of a single collection for a sink.
+type PeerStatus struct {
+	// Synced is true if the sink has acknowledged the current snapshot version.
+	Synced bool
+	// LastRequestedVersion is the version most recently acknowledged or rejected by the sink.
+	LastRequestedVersion string
+	// LastPushedVersion is the version most recently pushed to the sink.
+	LastPushedVersion string
+	// LastAckTime is the time of the most recent acknowledgement by the sink.
+	LastAckTime time.Time
+	// NackCount is the number of times the sink rejected a pushed version.
+	NackCount int
+}
+
+// PeerInfo is the sync status of a single collection for the identified sink.
+type PeerInfo struct {
+	PeerKey
+	PeerStatus
+}
+
+// must be called with the cache lock held
+func (s *PeerStatus) requested(request *source.Request, now time.Time) {
+	if request.VersionInfo == "" {
+		// initial request; there is nothing to ACK or NACK.
+		return
+	}
+
+	s.LastRequestedVersion = request.VersionInfo
+	if request.ErrorDetail != nil {
+		s.NackCount++
+		s.Synced = false
+	} else {
+		s.LastAckTime = now
+	}
+}
+
+// must be called with the cache lock held
+func (s *PeerStatus) pushed(version string) {
+	s.LastPushedVersion = version
+	s.Synced = false
+}
@@ -25,6 +25,7 @@ import (
 	"google.golang.org/grpc/peer"
 
 	mcp "istio.io/api/mcp/v1alpha1"
+	rpc "istio.io/gogo-genproto/googleapis/google/rpc"
 	"istio.io/libistio/pkg/mcp/internal"
 	"istio.io/libistio/pkg/mcp/monitoring"
 	"istio.io/libistio/pkg/mcp/rate"
@@ -44,6 +45,9 @@ type Request struct {
 	VersionInfo string
 	SinkNode    *mcp.SinkNode
 
+	// ErrorDetail is set if VersionInfo was NACK'd by the sink.
+	ErrorDetail *rpc.Status
+
 	// hidden
 	incremental bool
 }
@@ -94,6 +98,17 @@ type CollectionOptions struct {
 	// Incremental updates are only used if the sink requests it (per request)
 	// and the source decides to make use of it.
 	Incremental bool
+
+	// Priority determines the order in which pending responses for different
+	// collections are pushed to a sink. Responses for collections with a higher
+	// priority are pushed first. Collections with equal priority are pushed in the
+	// order their responses were first queued. Defaults to zero.
+	Priority int
+
+	// DependsOn lists the names of collections whose pending responses must be
+	// pushed before a pending response for this collection, regardless of
+	// priority, e.g. ServiceEntries before VirtualServices.
+	DependsOn []string
 }
 
 // CollectionOptionsFromSlice returns a slice of collection options from
@@ -134,6 +149,11 @@ type Source struct {
 	collections    []CollectionOptions
 	reporter       monitoring.Reporter
 	requestLimiter rate.LimitFactory
+
+	// per-collection scheduling. Both are nil if every collection is scheduled
+	// in FIFO order.
+	priorities map[string]int
+	dependsOn  map[string][]string
 }
 
 // watch maintains local push state of the most recent watch per-type.
@@ -165,7 +185,7 @@ type connection struct {
 	reporter monitoring.Reporter
 	limiter  rate.Limit
 
-	queue *internal.UniqueQueue
+	queue internal.Queue
 }
 
 // New creates a new resource source.
@@ -176,9 +196,48 @@ func New(options *Options) *Source {
 		reporter:       options.Reporter,
 		requestLimiter: options.ConnRateLimiter,
 	}
+
+	for _, collection := range options.CollectionsOptions {
+		if collection.Priority == 0 && len(collection.DependsOn) == 0 {
+			continue
+		}
+		if s.priorities == nil {
+			s.priorities = make(map[string]int)
+			s.dependsOn = make(map[string][]string)
+		}
+		if collection.Priority != 0 {
+			s.priorities[collection.Name] = collection.Priority
+		}
+		if len(collection.DependsOn) > 0 {
+			s.dependsOn[collection.Name] = collection.DependsOn
+		}
+	}
+
+	// Validate the dependencies once up front rather than for every connection.
+	if s.priorities != nil {
+		if _, err := internal.NewPriorityUniqueScheduledQueue(0, s.priorities, s.dependsOn); err != nil {
+			scope.Errorf("MCP: ignoring collection dependencies: %v", err)
+			s.dependsOn = nil
+		}
+	}
+
 	return s
 }
 
+func (s *Source) newQueue() internal.Queue {
+	if s.priorities == nil {
+		return internal.NewUniqueScheduledQueue(len(s.collections))
+	}
+
+	q, err := internal.NewPriorityUniqueScheduledQueue(len(s.collections), s.priorities, s.dependsOn)
+	if err != nil {
+		// dependencies are validated in New(), so this should not happen.
+		scope.Errorf("MCP: falling back to FIFO queue: %v", err)
+		return internal.NewUniqueScheduledQueue(len(s.collections))
+	}
+	return q
+}
+
 func (s *Source) newConnection(stream Stream) *connection {
 	peerAddr := "0.0.0.0"
 
@@ -199,7 +258,7 @@ func (s *Source) newConnection(stream Stream) *connection {
 		id:       atomic.AddInt64(&s.nextStreamID, 1),
 		reporter: s.reporter,
 		limiter:  s.requestLimiter.Create(),
-		queue:    internal.NewUniqueScheduledQueue(len(s.collections)),
+		queue:    s.newQueue(),
 	}
 
 	collections := make([]string, 0, len(s.collections))
@@ -444,6 +503,9 @@ func (con *connection) processClientRequest(req *mcp.RequestResources) error {
 			VersionInfo: versionInfo,
 			incremental: req.Incremental,
 		}
+		if versionInfo != "" {
+			sr.ErrorDetail = req.ErrorDetail
+		}
 		w.cancel = con.watcher.Watch(sr, con.queueResponse, con.peerAddr)
 	} else {
 		// This error path should not happen! Skip any requests that don't match the